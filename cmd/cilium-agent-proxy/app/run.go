@@ -9,6 +9,9 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -77,7 +80,7 @@ func handleCIDRIdentities(w http.ResponseWriter, r *http.Request) {
 		// https://docs.cilium.io/en/stable/internals/security-identities/
 		if (1<<24) <= i.ID && i.ID < (1<<25) {
 			return !slices.ContainsFunc(i.Labels, func(l string) bool {
-				return strings.HasPrefix(l, "cidr:") || strings.HasPrefix(l, "cidrgroup:")
+				return strings.HasPrefix(l, "cidr:") || strings.HasPrefix(l, "cidrgroup:") || strings.HasPrefix(l, "fqdn:")
 			})
 		}
 		return true
@@ -94,6 +97,271 @@ func handleCIDRIdentities(w http.ResponseWriter, r *http.Request) {
 	renderJSON(w, r.URL.Path, data, http.StatusOK)
 }
 
+func handleSelectors(w http.ResponseWriter, r *http.Request) {
+	url := "http://localhost/v1/policy/selectors"
+	resp, err := socketClient.Get(url)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to call Cilium API", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+
+	// https://github.com/cilium/cilium/blob/main/api/v1/models/selector_identity_mapping.go
+	type Selector struct {
+		Selector   string  `json:"selector,omitempty"`
+		Identities []int64 `json:"identities"`
+	}
+	var selectors []Selector
+	{
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			renderError(w, r.URL.Path, "failed to read data", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(data, &selectors); err != nil {
+			renderError(w, r.URL.Path, "failed to unmarshal result", http.StatusInternalServerError)
+			return
+		}
+	}
+	slices.SortFunc(selectors, func(x, y Selector) int {
+		return strings.Compare(x.Selector, y.Selector)
+	})
+
+	// Do not expose excessive info to client
+	data, err := json.Marshal(selectors)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to marshal result", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, r.URL.Path, data, http.StatusOK)
+}
+
+func handlePolicyMapMax(w http.ResponseWriter, r *http.Request) {
+	url := "http://localhost/v1/config"
+	resp, err := socketClient.Get(url)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to call Cilium API", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+
+	// https://github.com/cilium/cilium/blob/main/api/v1/models/daemon_configuration_status.go
+	// The map is made from the fields of option.Config, and PolicyMapEntries is set by bpf-policy-map-max.
+	var config struct {
+		Status struct {
+			DaemonConfigurationMap struct {
+				PolicyMapEntries int `json:"PolicyMapEntries"`
+			} `json:"daemonConfigurationMap"`
+		} `json:"status"`
+	}
+	{
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			renderError(w, r.URL.Path, "failed to read data", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			renderError(w, r.URL.Path, "failed to unmarshal result", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Do not expose excessive info to client
+	var result struct {
+		PolicyMapMax int `json:"policy_map_max"`
+	}
+	result.PolicyMapMax = config.Status.DaemonConfigurationMap.PolicyMapEntries
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to marshal result", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, r.URL.Path, data, http.StatusOK)
+}
+
+func handlePolicyRevision(w http.ResponseWriter, r *http.Request) {
+	url := "http://localhost/v1/policy"
+	resp, err := socketClient.Get(url)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to call Cilium API", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+
+	// https://github.com/cilium/cilium/blob/main/api/v1/models/policy.go
+	var policy struct {
+		Revision int64 `json:"revision,omitempty"`
+	}
+	{
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			renderError(w, r.URL.Path, "failed to read data", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(data, &policy); err != nil {
+			renderError(w, r.URL.Path, "failed to unmarshal result", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Do not expose excessive info to client, such as the policy itself
+	data, err := json.Marshal(policy)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to marshal result", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, r.URL.Path, data, http.StatusOK)
+}
+
+func handleHostEndpoint(w http.ResponseWriter, r *http.Request) {
+	url := "http://localhost/v1/endpoint?labels=reserved:host"
+	resp, err := socketClient.Get(url)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to call Cilium API", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+
+	// https://github.com/cilium/cilium/blob/main/api/v1/models/endpoint.go
+	type Endpoint struct {
+		ID int64 `json:"id,omitempty"`
+	}
+	var eps []Endpoint
+	{
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			renderError(w, r.URL.Path, "failed to read data", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(data, &eps); err != nil {
+			renderError(w, r.URL.Path, "failed to unmarshal result", http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(eps) != 1 {
+		renderError(w, r.URL.Path, fmt.Sprintf("found %d host endpoints", len(eps)), http.StatusNotFound)
+		return
+	}
+
+	// Do not expose excessive info to client
+	data, err := json.Marshal(eps[0])
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to marshal result", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, r.URL.Path, data, http.StatusOK)
+}
+
+// fqdnPattern accepts DNS names and the wildcard patterns of "cilium fqdn cache list --matchpattern"
+var fqdnPattern = regexp.MustCompile(`^[A-Za-z0-9*]([A-Za-z0-9*.-]{0,252})?$`)
+
+func handleFQDN(w http.ResponseWriter, r *http.Request) {
+	param := r.URL.Path[len("/fqdn/"):]
+	if !fqdnPattern.MatchString(param) {
+		renderError(w, r.URL.Path, "failed to read FQDN", http.StatusBadRequest)
+		return
+	}
+
+	// https://github.com/cilium/cilium/blob/main/api/v1/models/d_n_s_lookup.go
+	type DNSLookup struct {
+		FQDN string   `json:"fqdn,omitempty"`
+		IPs  []string `json:"ips,omitempty"`
+	}
+	var lookups []DNSLookup
+	{
+		resp, err := socketClient.Get("http://localhost/v1/fqdn/cache?matchpattern=" + url.QueryEscape(param))
+		if err != nil {
+			renderError(w, r.URL.Path, "failed to call Cilium API", http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+
+		// 404 means the name is not in the cache
+		if resp.StatusCode == http.StatusOK {
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				renderError(w, r.URL.Path, "failed to read data", http.StatusInternalServerError)
+				return
+			}
+			if err := json.Unmarshal(data, &lookups); err != nil {
+				renderError(w, r.URL.Path, "failed to unmarshal result", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	type FQDNIdentity struct {
+		FQDN     string `json:"fqdn"`
+		IP       string `json:"ip"`
+		Identity int64  `json:"identity"`
+	}
+	result := make([]FQDNIdentity, 0)
+	for _, l := range lookups {
+		for _, ip := range l.IPs {
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				continue
+			}
+			id, err := lookupIPIdentity(addr)
+			if err != nil {
+				renderError(w, r.URL.Path, "failed to call Cilium API", http.StatusInternalServerError)
+				return
+			}
+			result = append(result, FQDNIdentity{
+				FQDN:     strings.TrimSuffix(l.FQDN, "."),
+				IP:       addr.String(),
+				Identity: id,
+			})
+		}
+	}
+	slices.SortFunc(result, func(x, y FQDNIdentity) int {
+		return cmp.Or(strings.Compare(x.FQDN, y.FQDN), strings.Compare(x.IP, y.IP))
+	})
+	result = slices.Compact(result)
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		renderError(w, r.URL.Path, "failed to marshal result", http.StatusInternalServerError)
+		return
+	}
+	renderJSON(w, r.URL.Path, data, http.StatusOK)
+}
+
+// lookupIPIdentity returns the identity of an IP address in the ipcache.
+// An address missing in the ipcache is treated as the world.
+func lookupIPIdentity(addr netip.Addr) (int64, error) {
+	prefix := netip.PrefixFrom(addr, addr.BitLen())
+	resp, err := socketClient.Get("http://localhost/v1/ip?cidr=" + url.QueryEscape(prefix.String()))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// https://github.com/cilium/cilium/blob/main/api/v1/models/ip_list_entry.go
+	type IPListEntry struct {
+		CIDR     string `json:"cidr"`
+		Identity int64  `json:"identity"`
+	}
+	var entries []IPListEntry
+	if resp.StatusCode == http.StatusOK {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return 0, err
+		}
+	}
+	for _, e := range entries {
+		if e.CIDR == prefix.String() {
+			return e.Identity, nil
+		}
+	}
+	// reserved:world
+	return 2, nil
+}
+
 func handlePolicy(w http.ResponseWriter, r *http.Request) {
 	param := r.URL.Path[len("/policy/"):]
 	if len(param) == 0 {
@@ -171,7 +439,12 @@ func subMain() error {
 
 	http.HandleFunc("/v1/endpoint/", handleEndpoint)
 	http.HandleFunc("/cidr-identities", handleCIDRIdentities)
+	http.HandleFunc("/fqdn/", handleFQDN)
+	http.HandleFunc("/host-endpoint", handleHostEndpoint)
 	http.HandleFunc("/policy/", handlePolicy)
+	http.HandleFunc("/policy-map-max", handlePolicyMapMax)
+	http.HandleFunc("/policy-revision", handlePolicyRevision)
+	http.HandleFunc("/selectors", handleSelectors)
 	http.HandleFunc("/version", handleVersion)
 
 	return server.ListenAndServe()
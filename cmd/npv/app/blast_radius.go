@@ -0,0 +1,264 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+)
+
+var blastRadiusOptions struct {
+	hops int
+}
+
+func init() {
+	blastRadiusCmd.Flags().IntVar(&blastRadiusOptions.hops, "hops", 3, "maximum number of hops to follow")
+	rootCmd.AddCommand(blastRadiusCmd)
+}
+
+var blastRadiusCmd = &cobra.Command{
+	Use:   "blast-radius NAMESPACE/POD",
+	Short: "List workloads reachable from a pod within N hops",
+	Long: `List workloads reachable from a pod within N hops
+
+A hop from A to B is counted when A allows the egress traffic to B and B allows the ingress traffic from A.
+Each reachable workload is shown with one of the shortest paths and the ports allowed on each hop.`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBlastRadius(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+	},
+	ValidArgsFunction: completeNamespacePods,
+}
+
+type blastRadiusHop struct {
+	Identity uint32   `json:"identity"`
+	Workload string   `json:"workload"`
+	Ports    []string `json:"ports"`
+}
+
+type blastRadiusEntry struct {
+	Hops          int              `json:"hops"`
+	Identity      uint32           `json:"identity"`
+	Namespace     string           `json:"namespace"`
+	Workload      string           `json:"workload"`
+	EndpointCount int              `json:"endpoint_count"`
+	Path          []blastRadiusHop `json:"path"`
+}
+
+// fetchIdentityPolicies queries the policy maps of example endpoints of the identities in parallel.
+// Endpoints with the same identity share the same policy, so one endpoint per identity is enough.
+// Identities without endpoints or whose example pod is gone are cached with no policies, so no hop goes through them.
+func fetchIdentityPolicies(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, ids []uint32, cache map[uint32][]proxy.PolicyEntry) error {
+	podIdentities := make(map[types.NamespacedName]uint32)
+	pods := make([]*corev1.Pod, 0)
+	for _, id := range ids {
+		if _, ok := cache[id]; ok {
+			continue
+		}
+		example, err := getIdentityExample(ctx, dynamicClient, id)
+		if err != nil {
+			return err
+		}
+		if example == nil {
+			cache[id] = nil
+			continue
+		}
+		pod, err := clientset.CoreV1().Pods(example.GetNamespace()).Get(ctx, example.GetName(), metav1.GetOptions{})
+		if err != nil {
+			// The example pod may be deleted during the search, so the identity is left unresolved
+			fmt.Fprintf(stderr, "Warning: failed to resolve identity %d: %v\n", id, err)
+			cache[id] = nil
+			continue
+		}
+		podIdentities[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}] = id
+		pods = append(pods, pod)
+	}
+
+	result := mapNodeReduce(pods,
+		func() map[uint32][]proxy.PolicyEntry {
+			return make(map[uint32][]proxy.PolicyEntry)
+		},
+		func(pod *corev1.Pod) map[uint32][]proxy.PolicyEntry {
+			client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: failed to create Cilium client: %v\n", err)
+				return nil
+			}
+			policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			id := podIdentities[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]
+			return map[uint32][]proxy.PolicyEntry{id: policies}
+		},
+		func(x, y map[uint32][]proxy.PolicyEntry) map[uint32][]proxy.PolicyEntry {
+			maps.Copy(x, y)
+			return x
+		},
+	)
+	maps.Copy(cache, result)
+	return nil
+}
+
+func runBlastRadius(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	if blastRadiusOptions.hops < 1 {
+		return errors.New("--hops should be a positive number")
+	}
+	start, err := parseNamespacedName(name)
+	if err != nil {
+		return errors.New("pod should be specified as NAMESPACE/POD")
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	startIdentity, err := getPodIdentity(ctx, dynamicClient, start.Namespace, start.Name)
+	if err != nil {
+		return err
+	}
+	idEndpoints, err := getIdentityEndpoints(ctx, dynamicClient)
+	if err != nil {
+		return err
+	}
+	allIdentities := slices.Sorted(maps.Keys(idEndpoints))
+
+	// The policy map of the compromised pod itself is used for the first hop.
+	cache := make(map[uint32][]proxy.PolicyEntry)
+	{
+		client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, start.Namespace, start.Name)
+		if err != nil {
+			return fmt.Errorf("failed to create Cilium client: %w", err)
+		}
+		policies, err := client.QueryPolicyMap(ctx, start.Namespace, start.Name)
+		if err != nil {
+			return err
+		}
+		cache[startIdentity] = policies
+	}
+
+	// key: identity, value: one of the shortest paths from the start
+	paths := map[uint32][]blastRadiusHop{startIdentity: {}}
+	frontier := []uint32{startIdentity}
+	for hop := 1; hop <= blastRadiusOptions.hops && len(frontier) > 0; hop++ {
+		// key: sender identity, value: candidate receivers
+		candidates := make(map[uint32][]uint32)
+		needed := slices.Clone(frontier)
+		for _, x := range frontier {
+			peers := make([]uint32, 0)
+			for _, p := range cache[x] {
+				if !p.IsEgress() || p.IsDeny() {
+					continue
+				}
+				if p.Key.Identity == 0 {
+					peers = allIdentities
+					break
+				}
+				if _, ok := idEndpoints[p.Key.Identity]; ok {
+					peers = append(peers, p.Key.Identity)
+				}
+			}
+			peers = slices.DeleteFunc(slices.Clone(peers), func(id uint32) bool {
+				_, ok := paths[id]
+				return ok
+			})
+			slices.Sort(peers)
+			candidates[x] = slices.Compact(peers)
+			needed = append(needed, candidates[x]...)
+		}
+
+		if err := fetchIdentityPolicies(ctx, stderr, clientset, dynamicClient, needed, cache); err != nil {
+			return err
+		}
+
+		next := make([]uint32, 0)
+		for _, x := range frontier {
+			for _, y := range candidates[x] {
+				if _, ok := paths[y]; ok {
+					continue
+				}
+				rules := findAllowedRules(x, y, cache[x], cache[y])
+				if len(rules) == 0 {
+					continue
+				}
+
+				peers, err := getIdentityPeers(ctx, clientset, dynamicClient, y)
+				if err != nil {
+					return err
+				}
+				h := blastRadiusHop{
+					Identity: y,
+					Workload: peers.Workload,
+					Ports:    make([]string, len(rules)),
+				}
+				for i, r := range rules {
+					h.Ports[i] = r.String()
+				}
+				paths[y] = append(slices.Clone(paths[x]), h)
+				next = append(next, y)
+			}
+		}
+		frontier = next
+	}
+
+	arr := make([]blastRadiusEntry, 0, len(paths)-1)
+	for id, path := range paths {
+		if id == startIdentity {
+			continue
+		}
+		peers, err := getIdentityPeers(ctx, clientset, dynamicClient, id)
+		if err != nil {
+			return err
+		}
+		namespace := "-"
+		if example, err := getIdentityExample(ctx, dynamicClient, id); err != nil {
+			return err
+		} else if example != nil {
+			namespace = example.GetNamespace()
+		}
+		arr = append(arr, blastRadiusEntry{
+			Hops:          len(path),
+			Identity:      id,
+			Namespace:     namespace,
+			Workload:      peers.Workload,
+			EndpointCount: len(peers.Endpoints),
+			Path:          path,
+		})
+	}
+	slices.SortFunc(arr, func(x, y blastRadiusEntry) int {
+		if ret := x.Hops - y.Hops; ret != 0 {
+			return ret
+		}
+		if ret := strings.Compare(x.Namespace, y.Namespace); ret != 0 {
+			return ret
+		}
+		return strings.Compare(x.Workload, y.Workload)
+	})
+
+	header := []string{"HOPS:", "IDENTITY", "NAMESPACE", "WORKLOAD", "ENDPOINTS:", "|", "PATH", "PORTS"}
+	return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
+		p := arr[index]
+		path := make([]string, len(p.Path))
+		ports := make([]string, len(p.Path))
+		for i, h := range p.Path {
+			path[i] = fmt.Sprintf("%d. %s", i+1, h.Workload)
+			ports[i] = strings.Join(h.Ports, ",")
+		}
+		return []any{p.Hops, p.Identity, p.Namespace, p.Workload, p.EndpointCount, "|", path, ports}
+	})
+}
@@ -0,0 +1,245 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+var capacityOptions struct {
+	threshold float64
+	top       int
+}
+
+func init() {
+	capacityCmd.Flags().Float64Var(&capacityOptions.threshold, "threshold", 80, "warn when the utilization of a policy map exceeds this percentage")
+	capacityCmd.Flags().IntVar(&capacityOptions.top, "top", 3, "number of policies and selectors to show as top contributors")
+	addPodSelectorOption(capacityCmd)
+	rootCmd.AddCommand(capacityCmd)
+}
+
+var capacityCmd = &cobra.Command{
+	Use:   "capacity [POD]",
+	Short: "Show utilization of policy maps",
+	Long: `Show utilization of policy maps
+
+The number of entries in the policy map of each pod is compared with bpf-policy-map-max of the agent.
+Policies and selectors contributing the most entries are listed, where an entry is counted for every
+policy and selector which may have generated it. The second table summarizes the pods per namespace.`,
+
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runCapacity(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), "")
+		} else {
+			return runCapacity(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+		}
+	},
+	ValidArgsFunction: completePods,
+}
+
+type capacityContributor struct {
+	Name    string `json:"name"`
+	Entries int    `json:"entries"`
+}
+
+func (c capacityContributor) String() string {
+	return fmt.Sprintf("%s (%d)", c.Name, c.Entries)
+}
+
+type capacityEntry struct {
+	Namespace   string                `json:"namespace"`
+	Name        string                `json:"name"`
+	Node        string                `json:"node"`
+	Entries     int                   `json:"entries"`
+	Max         int                   `json:"max"`
+	Utilization float64               `json:"utilization"`
+	Policies    []capacityContributor `json:"policies"`
+	Selectors   []capacityContributor `json:"selectors"`
+}
+
+type capacityNamespace struct {
+	Namespace      string  `json:"namespace"`
+	Pods           int     `json:"pods"`
+	Entries        int     `json:"entries"`
+	MaxUtilization float64 `json:"max_utilization"`
+	OverThreshold  int     `json:"over_threshold"`
+}
+
+type capacityResult struct {
+	Pods       []capacityEntry     `json:"pods"`
+	Namespaces []capacityNamespace `json:"namespaces"`
+}
+
+// topContributors returns the contributors with the most entries.
+func topContributors(counts map[string]int, n int) []capacityContributor {
+	ret := make([]capacityContributor, 0, len(counts))
+	for _, k := range slices.Sorted(maps.Keys(counts)) {
+		ret = append(ret, capacityContributor{Name: k, Entries: counts[k]})
+	}
+	slices.SortStableFunc(ret, func(x, y capacityContributor) int { return -cmp.Compare(x.Entries, y.Entries) })
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+func runCapacityOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) (*capacityEntry, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+	policyMapMax, err := client.GetPolicyMapMax(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := queryEndpointPolicy(ctx, stderr, clientset, dynamicClient, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]int)
+	selectors := make(map[string]int)
+	for i := range e.Entries {
+		p := &e.Entries[i]
+		for _, ref := range e.attributeEntry(p) {
+			policies[ref.String()]++
+		}
+		seen := make(map[string]bool)
+		for j := range e.Rules {
+			r := &e.Rules[j]
+			if !seen[r.Selector] && r.produces(p, e.Selectors) {
+				seen[r.Selector] = true
+				selectors[r.Selector]++
+			}
+		}
+	}
+
+	return &capacityEntry{
+		Namespace:   pod.Namespace,
+		Name:        pod.Name,
+		Node:        pod.Spec.NodeName,
+		Entries:     len(e.Entries),
+		Max:         policyMapMax,
+		Utilization: float64(len(e.Entries)) * 100 / float64(policyMapMax),
+		Policies:    topContributors(policies, capacityOptions.top),
+		Selectors:   topContributors(selectors, capacityOptions.top),
+	}, nil
+}
+
+func summarizeCapacity(pods []capacityEntry) []capacityNamespace {
+	ret := make([]capacityNamespace, 0)
+	for _, p := range pods {
+		if len(ret) == 0 || ret[len(ret)-1].Namespace != p.Namespace {
+			ret = append(ret, capacityNamespace{Namespace: p.Namespace})
+		}
+		ns := &ret[len(ret)-1]
+		ns.Pods++
+		ns.Entries += p.Entries
+		ns.MaxUtilization = max(ns.MaxUtilization, p.Utilization)
+		if p.Utilization > capacityOptions.threshold {
+			ns.OverThreshold++
+		}
+	}
+	return ret
+}
+
+func runCapacity(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	if capacityOptions.threshold < 0 || capacityOptions.threshold > 100 {
+		return errors.New("--threshold should be between 0 and 100")
+	}
+	if capacityOptions.top < 0 {
+		return errors.New("--top should not be negative")
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return err
+	}
+
+	arr := mapNodeReduce(pods,
+		func() []capacityEntry {
+			return make([]capacityEntry, 0)
+		},
+		func(pod *corev1.Pod) []capacityEntry {
+			entry, err := runCapacityOnPod(ctx, stderr, clientset, dynamicClient, pod)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return []capacityEntry{*entry}
+		},
+		func(x, y []capacityEntry) []capacityEntry {
+			return append(x, y...)
+		},
+	)
+	slices.SortFunc(arr, func(x, y capacityEntry) int {
+		return cmp.Or(strings.Compare(x.Namespace, y.Namespace), strings.Compare(x.Name, y.Name))
+	})
+
+	for _, p := range arr {
+		if p.Utilization > capacityOptions.threshold {
+			fmt.Fprintf(stderr, "Warning: %s/%s uses %d of %d policy map entries (%.1f%%)\n", p.Namespace, p.Name, p.Entries, p.Max, p.Utilization)
+		}
+	}
+
+	result := capacityResult{
+		Pods:       arr,
+		Namespaces: summarizeCapacity(arr),
+	}
+	return writeCapacity(stdout, &result)
+}
+
+func writeCapacity(w io.Writer, result *capacityResult) error {
+	if rootOptions.output == OutputJson {
+		return writeSimpleOrJson(w, result, nil, 0, nil)
+	}
+
+	header := []string{"NAMESPACE", "NAME", "NODE", "|", "ENTRIES:", "MAX:", "UTILIZATION:", "|", "TOP-POLICIES", "TOP-SELECTORS"}
+	err := writeSimpleOrJson(w, result, header, len(result.Pods), func(index int) []any {
+		p := result.Pods[index]
+		policies := make([]string, len(p.Policies))
+		for i, c := range p.Policies {
+			policies[i] = c.String()
+		}
+		selectors := make([]string, len(p.Selectors))
+		for i, c := range p.Selectors {
+			selectors[i] = c.String()
+		}
+		return []any{p.Namespace, p.Name, p.Node, "|", p.Entries, p.Max, fmt.Sprintf("%.1f%%", p.Utilization), "|", policies, selectors}
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+
+	header = []string{"NAMESPACE", "PODS:", "ENTRIES:", "MAX-UTILIZATION:", "OVER-THRESHOLD:"}
+	return writeSimpleOrJson(w, result, header, len(result.Namespaces), func(index int) []any {
+		p := result.Namespaces[index]
+		return []any{p.Namespace, p.Pods, p.Entries, fmt.Sprintf("%.1f%%", p.MaxUtilization), p.OverThreshold}
+	})
+}
@@ -9,4 +9,10 @@ const (
 
 	trafficRoleSender   = "Sender"
 	trafficRoleReceiver = "Receiver"
+	trafficRoleOverall  = "Overall"
+
+	verdictAllowed = "ALLOWED"
+	verdictDenied  = "DENIED"
+	verdictPartial = "PARTIAL"
+	verdictUnknown = "UNKNOWN"
 )
@@ -0,0 +1,232 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+func init() {
+	addPodSelectorOption(coverageCmd)
+	rootCmd.AddCommand(coverageCmd)
+}
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage [POD]",
+	Short: "Show whether network policies are enforced on pods",
+	Long: `Show whether network policies are enforced on pods
+
+Enforcement is read from the realized policy of each endpoint. Directions in audit mode are not regarded as enforced,
+because nothing is dropped. DEFAULT-DENY is false when the endpoint is not enforced or when an entry allows all traffic
+in the direction, e.g. by enableDefaultDeny: false.
+The second table summarizes the pods per namespace.`,
+
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runCoverage(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), "")
+		} else {
+			return runCoverage(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+		}
+	},
+	ValidArgsFunction: completePods,
+}
+
+type coverageDirection struct {
+	Enforced    bool `json:"enforced"`
+	DefaultDeny bool `json:"default_deny"`
+}
+
+type coverageEntry struct {
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Selected  bool              `json:"selected"`
+	Ingress   coverageDirection `json:"ingress"`
+	Egress    coverageDirection `json:"egress"`
+	Audit     bool              `json:"audit"`
+}
+
+type coverageNamespace struct {
+	Namespace          string `json:"namespace"`
+	Pods               int    `json:"pods"`
+	Selected           int    `json:"selected"`
+	IngressEnforced    int    `json:"ingress_enforced"`
+	IngressDefaultDeny int    `json:"ingress_default_deny"`
+	EgressEnforced     int    `json:"egress_enforced"`
+	EgressDefaultDeny  int    `json:"egress_default_deny"`
+	Audit              int    `json:"audit"`
+}
+
+type coverageResult struct {
+	Pods       []coverageEntry     `json:"pods"`
+	Namespaces []coverageNamespace `json:"namespaces"`
+}
+
+// isAllowAllEntry reports whether the entry allows all traffic in its direction.
+func isAllowAllEntry(p *proxy.PolicyEntry) bool {
+	return p.IsAllow() && p.Key.Identity == 0 && p.IsWildcardProtocol()
+}
+
+func runCoverageOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) (*coverageEntry, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.GetEndpointResponse(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint info: %w", err)
+	}
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := coverageEntry{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+	}
+	var realized *models.EndpointPolicy
+	if response.Payload.Status.Policy != nil {
+		realized = response.Payload.Status.Policy.Realized
+	}
+	if realized == nil {
+		return &entry, nil
+	}
+
+	// Audit mode drops nothing, so such a direction is not regarded as enforced
+	ingressEnforced, ingressAudit := isPolicyEnforced(realized.PolicyEnabled, false)
+	egressEnforced, egressAudit := isPolicyEnforced(realized.PolicyEnabled, true)
+	entry.Ingress.Enforced = ingressEnforced && !ingressAudit
+	entry.Egress.Enforced = egressEnforced && !egressAudit
+	entry.Audit = ingressAudit || egressAudit
+
+	for _, r := range expandRealizedRules(realized.L4, true, true) {
+		if len(r.DerivedFrom) > 0 {
+			entry.Selected = true
+			break
+		}
+	}
+
+	entry.Ingress.DefaultDeny = entry.Ingress.Enforced
+	entry.Egress.DefaultDeny = entry.Egress.Enforced
+	for i := range policies {
+		p := &policies[i]
+		if !isAllowAllEntry(p) {
+			continue
+		}
+		if p.IsEgress() {
+			entry.Egress.DefaultDeny = false
+		} else {
+			entry.Ingress.DefaultDeny = false
+		}
+	}
+	return &entry, nil
+}
+
+func summarizeCoverage(pods []coverageEntry) []coverageNamespace {
+	ret := make([]coverageNamespace, 0)
+	for _, p := range pods {
+		if len(ret) == 0 || ret[len(ret)-1].Namespace != p.Namespace {
+			ret = append(ret, coverageNamespace{Namespace: p.Namespace})
+		}
+		ns := &ret[len(ret)-1]
+		ns.Pods++
+		if p.Selected {
+			ns.Selected++
+		}
+		if p.Ingress.Enforced {
+			ns.IngressEnforced++
+		}
+		if p.Ingress.DefaultDeny {
+			ns.IngressDefaultDeny++
+		}
+		if p.Egress.Enforced {
+			ns.EgressEnforced++
+		}
+		if p.Egress.DefaultDeny {
+			ns.EgressDefaultDeny++
+		}
+		if p.Audit {
+			ns.Audit++
+		}
+	}
+	return ret
+}
+
+func runCoverage(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return err
+	}
+
+	arr := mapNodeReduce(pods,
+		func() []coverageEntry {
+			return make([]coverageEntry, 0)
+		},
+		func(pod *corev1.Pod) []coverageEntry {
+			entry, err := runCoverageOnPod(ctx, stderr, clientset, dynamicClient, pod)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return []coverageEntry{*entry}
+		},
+		func(x, y []coverageEntry) []coverageEntry {
+			return append(x, y...)
+		},
+	)
+	slices.SortFunc(arr, func(x, y coverageEntry) int {
+		return cmp.Or(strings.Compare(x.Namespace, y.Namespace), strings.Compare(x.Name, y.Name))
+	})
+
+	result := coverageResult{
+		Pods:       arr,
+		Namespaces: summarizeCoverage(arr),
+	}
+	return writeCoverage(stdout, &result)
+}
+
+func writeCoverage(w io.Writer, result *coverageResult) error {
+	if rootOptions.output == OutputJson {
+		return writeSimpleOrJson(w, result, nil, 0, nil)
+	}
+
+	header := []string{"NAMESPACE", "NAME", "|", "SELECTED", "|", "INGRESS-ENFORCED", "INGRESS-DEFAULT-DENY", "|", "EGRESS-ENFORCED", "EGRESS-DEFAULT-DENY", "|", "AUDIT"}
+	err := writeSimpleOrJson(w, result, header, len(result.Pods), func(index int) []any {
+		p := result.Pods[index]
+		return []any{p.Namespace, p.Name, "|", p.Selected, "|", p.Ingress.Enforced, p.Ingress.DefaultDeny, "|", p.Egress.Enforced, p.Egress.DefaultDeny, "|", p.Audit}
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+
+	header = []string{"NAMESPACE", "PODS:", "|", "SELECTED:", "|", "INGRESS-ENFORCED:", "INGRESS-DEFAULT-DENY:", "|", "EGRESS-ENFORCED:", "EGRESS-DEFAULT-DENY:", "|", "AUDIT:"}
+	return writeSimpleOrJson(w, result, header, len(result.Namespaces), func(index int) []any {
+		p := result.Namespaces[index]
+		return []any{p.Namespace, p.Pods, "|", p.Selected, "|", p.IngressEnforced, p.IngressDefaultDeny, "|", p.EgressEnforced, p.EgressDefaultDeny, "|", p.Audit}
+	})
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/cidr"
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+func init() {
+	addPodSelectorOption(exposureCmd)
+	rootCmd.AddCommand(exposureCmd)
+}
+
+var exposureCmd = &cobra.Command{
+	Use:   "exposure [POD]",
+	Short: "List pods exposed to the internet",
+	Long: `List pods exposed to the internet
+
+Policy maps of the selected pods are scanned for ingress allow entries from any identity, the world identities,
+or CIDR identities overlapping public CIDRs, and for egress allow entries to them with a wildcard port.
+Entries are ranked by the breadth of the allowed ports and then by the observed traffic.`,
+
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runExposure(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), "")
+		} else {
+			return runExposure(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+		}
+	},
+	ValidArgsFunction: completePods,
+}
+
+type exposureEntry struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Workload  string `json:"workload"`
+	Direction string `json:"direction"`
+	Identity  uint32 `json:"identity"`
+	Peer      string `json:"peer"`
+	Port      string `json:"port"`
+	Breadth   uint64 `json:"breadth"`
+	Bytes     uint64 `json:"bytes"`
+	Requests  uint64 `json:"requests"`
+}
+
+// portBreadth returns the number of protocol and port pairs an entry allows.
+func portBreadth(p *proxy.PolicyEntry) uint64 {
+	switch {
+	case p.IsWildcardProtocol():
+		return 256 * 65536
+	case p.IsWildcardPort():
+		return 65536
+	default:
+		return 1 << (16 - uint64(p.Key.GetPortPrefixLen()))
+	}
+}
+
+func compareExposureEntry(x, y *exposureEntry) int {
+	return cmp.Or(
+		-cmp.Compare(x.Breadth, y.Breadth),
+		-cmp.Compare(x.Bytes, y.Bytes),
+		strings.Compare(x.Namespace, y.Namespace),
+		strings.Compare(x.Name, y.Name),
+		strings.Compare(x.Direction, y.Direction),
+		cmp.Compare(x.Identity, y.Identity),
+		strings.Compare(x.Port, y.Port),
+	)
+}
+
+func runExposureOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) ([]exposureEntry, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// MakeCIDRFilter also accepts the identity 0 and the world identities
+	filter := proxy.MakeAllFilter(
+		proxy.MakeBasicFilter(true, true, true, false, true, true),
+		func(ctx context.Context, client *proxy.Client, p *proxy.PolicyEntry) (bool, error) {
+			return p.IsIngress() || p.IsWildcardPort(), nil
+		},
+		proxy.MakeCIDRFilter(true, true, cidr.PublicCIDRSet),
+	)
+	policies, err = proxy.FilterPolicyMap(ctx, client, policies, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	workload, err := getPodWorkload(ctx, clientset, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]exposureEntry, len(policies))
+	for i, p := range policies {
+		entry := exposureEntry{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Workload:  workload,
+			Direction: directionIngress,
+			Identity:  p.Key.Identity,
+			Port:      makeReachMatrixRule(&p).String(),
+			Breadth:   portBreadth(&p),
+			Bytes:     p.Bytes,
+			Requests:  p.Packets,
+		}
+		if p.IsEgress() {
+			entry.Direction = directionEgress
+		}
+		idObj := identity.NumericIdentity(p.Key.Identity)
+		switch {
+		case p.Key.Identity == 0:
+			entry.Peer = "ANY"
+		case idObj.HasLocalScope():
+			entry.Peer, err = describeLocalIdentity(ctx, client, p.Key.Identity)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			entry.Peer = "reserved:" + idObj.String()
+		}
+		ret[i] = entry
+	}
+	return ret, nil
+}
+
+func runExposure(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return err
+	}
+
+	arr := mapNodeReduce(pods,
+		func() []exposureEntry {
+			return make([]exposureEntry, 0)
+		},
+		func(pod *corev1.Pod) []exposureEntry {
+			entries, err := runExposureOnPod(ctx, stderr, clientset, dynamicClient, pod)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return entries
+		},
+		func(x, y []exposureEntry) []exposureEntry {
+			return append(x, y...)
+		},
+	)
+	slices.SortFunc(arr, func(x, y exposureEntry) int { return compareExposureEntry(&x, &y) })
+
+	header := []string{"NAMESPACE", "NAME", "WORKLOAD", "|", "DIRECTION", "IDENTITY", "PEER", "PORT", "|", "BYTES:", "REQUESTS:"}
+	return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
+		p := arr[index]
+		return []any{p.Namespace, p.Name, p.Workload, "|", p.Direction, p.Identity, p.Peer, p.Port, "|", formatWithUnits(p.Bytes), formatWithUnits(p.Requests)}
+	})
+}
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/gvr"
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+var healthEndpointsOptions struct {
+	all bool
+}
+
+func init() {
+	healthEndpointsCmd.Flags().BoolVar(&healthEndpointsOptions.all, "all", false, "show healthy endpoints as well")
+	addPodSelectorOption(healthEndpointsCmd)
+	healthCmd.AddCommand(healthEndpointsCmd)
+}
+
+var healthEndpointsCmd = &cobra.Command{
+	Use:   "endpoints [POD]",
+	Short: "List endpoints whose policies may not be realized",
+	Long: `List endpoints whose policies may not be realized
+
+An endpoint is reported when one of the following is observed:
+  - the realized policy revision is behind the policy revision of the agent
+  - the endpoint is not in the ready state, e.g. regenerating, waiting-for-identity, or not-ready
+  - the latest status change of the endpoint is a failure, e.g. a regeneration failure
+  - a controller of the endpoint keeps failing
+Pods without CiliumEndpoints or identities are reported as well. The second table summarizes the endpoints per node.`,
+
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runHealthEndpoints(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), "")
+		} else {
+			return runHealthEndpoints(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+		}
+	},
+	ValidArgsFunction: completePods,
+}
+
+type healthEndpointEntry struct {
+	Node             string   `json:"node"`
+	Namespace        string   `json:"namespace"`
+	Name             string   `json:"name"`
+	EndpointID       int64    `json:"endpoint_id"`
+	State            string   `json:"state"`
+	RealizedRevision int64    `json:"realized_revision"`
+	AgentRevision    int64    `json:"agent_revision"`
+	Issues           []string `json:"issues"`
+}
+
+type healthNode struct {
+	Node          string `json:"node"`
+	Endpoints     int    `json:"endpoints"`
+	Unhealthy     int    `json:"unhealthy"`
+	AgentRevision int64  `json:"agent_revision"`
+}
+
+type healthEndpointsResult struct {
+	Endpoints []healthEndpointEntry `json:"endpoints"`
+	Nodes     []healthNode          `json:"nodes"`
+}
+
+// findEndpointIssues examines the status of an endpoint returned by the agent.
+func findEndpointIssues(ep *models.Endpoint, agentRevision int64) (*healthEndpointEntry, error) {
+	if ep.Status == nil {
+		return nil, fmt.Errorf("status of endpoint %d is missing", ep.ID)
+	}
+
+	entry := healthEndpointEntry{
+		EndpointID:    ep.ID,
+		AgentRevision: agentRevision,
+		Issues:        make([]string, 0),
+	}
+	if ep.Status.State != nil {
+		entry.State = string(*ep.Status.State)
+	}
+	if entry.State != string(models.EndpointStateReady) {
+		entry.Issues = append(entry.Issues, "state is "+cmp.Or(entry.State, "unknown"))
+	}
+
+	if ep.Status.Policy != nil && ep.Status.Policy.Realized != nil {
+		entry.RealizedRevision = ep.Status.Policy.Realized.PolicyRevision
+	}
+	if entry.RealizedRevision < agentRevision {
+		entry.Issues = append(entry.Issues, fmt.Sprintf("realized revision %d is behind %d", entry.RealizedRevision, agentRevision))
+	}
+
+	// Timestamps are in RFC3339, so the latest change has the largest string
+	var latest *models.EndpointStatusChange
+	for _, l := range ep.Status.Log {
+		if l != nil && (latest == nil || l.Timestamp > latest.Timestamp) {
+			latest = l
+		}
+	}
+	if latest != nil && latest.Code == models.EndpointStatusChangeCodeFailed {
+		entry.Issues = append(entry.Issues, "last change failed: "+latest.Message)
+	}
+
+	for _, c := range ep.Status.Controllers {
+		if c == nil || c.Status == nil || c.Status.ConsecutiveFailureCount == 0 {
+			continue
+		}
+		entry.Issues = append(entry.Issues, fmt.Sprintf("controller %s failed %d times: %s", c.Name, c.Status.ConsecutiveFailureCount, c.Status.LastFailureMsg))
+	}
+	return &entry, nil
+}
+
+func runHealthEndpointOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) (*healthEndpointEntry, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	// Read the revision of the agent first, so that a policy update during the query is not reported as a lag
+	revision, err := client.GetPolicyRevision(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := client.DumpEndpoint(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	var ep models.Endpoint
+	if err := json.Unmarshal(data, &ep); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal endpoint: %w", err)
+	}
+
+	entry, err := findEndpointIssues(&ep, revision)
+	if err != nil {
+		return nil, err
+	}
+	entry.Node = pod.Spec.NodeName
+	entry.Namespace = pod.Namespace
+	entry.Name = pod.Name
+	return entry, nil
+}
+
+// makeSkippedHealthEndpoints reports pods which were skipped because their endpoints are not ready for examination.
+func makeSkippedHealthEndpoints(ctx context.Context, dynamicClient *dynamic.DynamicClient) ([]healthEndpointEntry, error) {
+	ret := make([]healthEndpointEntry, 0)
+	for _, p := range subject.GetSkippedPods() {
+		entry := healthEndpointEntry{
+			Node:      p.Node,
+			Namespace: p.Namespace,
+			Name:      p.Name,
+			State:     "-",
+		}
+		switch p.Reason {
+		case subject.SkipReasonNoEndpoint:
+			entry.Issues = []string{"CiliumEndpoint is missing"}
+		case subject.SkipReasonNoIdentity:
+			ep, err := dynamicClient.Resource(gvr.Endpoint).Namespace(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if id, ok, _ := unstructured.NestedInt64(ep.Object, "status", "id"); ok {
+				entry.EndpointID = id
+			}
+			if state, ok, _ := unstructured.NestedString(ep.Object, "status", "state"); ok {
+				entry.State = state
+			}
+			entry.Issues = []string{"identity is not assigned"}
+		default:
+			continue
+		}
+		ret = append(ret, entry)
+	}
+	return ret, nil
+}
+
+func summarizeHealthEndpoints(arr []healthEndpointEntry) []healthNode {
+	ret := make([]healthNode, 0)
+	for _, e := range arr {
+		if len(ret) == 0 || ret[len(ret)-1].Node != e.Node {
+			ret = append(ret, healthNode{Node: e.Node})
+		}
+		n := &ret[len(ret)-1]
+		n.Endpoints++
+		if len(e.Issues) > 0 {
+			n.Unhealthy++
+		}
+		n.AgentRevision = max(n.AgentRevision, e.AgentRevision)
+	}
+	return ret
+}
+
+func runHealthEndpoints(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return err
+	}
+	arr, err := makeSkippedHealthEndpoints(ctx, dynamicClient)
+	if err != nil {
+		return err
+	}
+
+	arr = append(arr, mapNodeReduce(pods,
+		func() []healthEndpointEntry {
+			return make([]healthEndpointEntry, 0)
+		},
+		func(pod *corev1.Pod) []healthEndpointEntry {
+			entry, err := runHealthEndpointOnPod(ctx, stderr, clientset, dynamicClient, pod)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return []healthEndpointEntry{*entry}
+		},
+		func(x, y []healthEndpointEntry) []healthEndpointEntry {
+			return append(x, y...)
+		},
+	)...)
+	slices.SortFunc(arr, func(x, y healthEndpointEntry) int {
+		return cmp.Or(strings.Compare(x.Node, y.Node), strings.Compare(x.Namespace, y.Namespace), strings.Compare(x.Name, y.Name))
+	})
+
+	result := healthEndpointsResult{
+		Nodes: summarizeHealthEndpoints(arr),
+	}
+	result.Endpoints = slices.DeleteFunc(arr, func(e healthEndpointEntry) bool {
+		return len(e.Issues) == 0 && !healthEndpointsOptions.all
+	})
+	return writeHealthEndpoints(stdout, &result)
+}
+
+func writeHealthEndpoints(w io.Writer, result *healthEndpointsResult) error {
+	if rootOptions.output == OutputJson {
+		return writeSimpleOrJson(w, result, nil, 0, nil)
+	}
+
+	header := []string{"NODE", "|", "NAMESPACE", "NAME", "ENDPOINT", "STATE", "|", "REALIZED:", "AGENT:", "|", "ISSUES"}
+	err := writeSimpleOrJson(w, result, header, len(result.Endpoints), func(index int) []any {
+		p := result.Endpoints[index]
+		issues := p.Issues
+		if len(issues) == 0 {
+			issues = []string{"-"}
+		}
+		return []any{p.Node, "|", p.Namespace, p.Name, p.EndpointID, p.State, "|", p.RealizedRevision, p.AgentRevision, "|", issues}
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+
+	header = []string{"NODE", "ENDPOINTS:", "UNHEALTHY:", "AGENT-REVISION:"}
+	return writeSimpleOrJson(w, result, header, len(result.Nodes), func(index int) []any {
+		p := result.Nodes[index]
+		return []any{p.Node, p.Endpoints, p.Unhealthy, p.AgentRevision}
+	})
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand/v2"
+	"io"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
@@ -18,6 +20,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/cybozu-go/network-policy-viewer/pkg/gvr"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
 )
 
 var (
@@ -25,8 +28,44 @@ var (
 	cachedIdentities        map[uint32]*unstructured.Unstructured
 	cachedIdentityEndpoints map[uint32][]*unstructured.Unstructured
 	cachedIdentityExample   = make(map[uint32]*unstructured.Unstructured)
+	cachedIdentityPeers     = make(map[uint32]*identityPeers)
+	cachedPodWorkloads      = make(map[types.NamespacedName]string)
 )
 
+// resetK8sCaches discards the cached resources, so that long-running commands such as watch see the latest state.
+func resetK8sCaches() {
+	k8sMutex.Lock()
+	defer k8sMutex.Unlock()
+
+	cachedIdentities = nil
+	cachedIdentityEndpoints = nil
+	cachedIdentityExample = make(map[uint32]*unstructured.Unstructured)
+	cachedIdentityPeers = make(map[uint32]*identityPeers)
+	cachedPodWorkloads = make(map[types.NamespacedName]string)
+}
+
+// reportSkippedPods tells the user which pods were excluded by subject.ListSubjectPods.
+func reportSkippedPods(w io.Writer) error {
+	skipped := subject.GetSkippedPods()
+	if len(skipped) == 0 {
+		return nil
+	}
+	if !commonOptions.showSkipped {
+		_, err := fmt.Fprintf(w, "Info: %d pods were skipped. Use --show-skipped to see the reasons.\n", len(skipped))
+		return err
+	}
+
+	var content struct {
+		Skipped []subject.SkippedPod `json:"skipped"`
+	}
+	content.Skipped = skipped
+	header := []string{"NAMESPACE", "NAME", "NODE", "REASON"}
+	return writeSimpleOrJson(w, content, header, len(skipped), func(index int) []any {
+		p := skipped[index]
+		return []any{p.Namespace, p.Name, p.Node, p.Reason}
+	})
+}
+
 func parseNamespacedName(nn string) (types.NamespacedName, error) {
 	li := strings.Split(nn, "/")
 	if len(li) != 2 {
@@ -123,7 +162,8 @@ func getIdentityEndpoints(ctx context.Context, d *dynamic.DynamicClient) (map[ui
 	return ret, nil
 }
 
-// getIdentityExample returns a consistent example endpoint for a CiliumIdentity within process' lifetime.
+// getIdentityExample returns a deterministic example endpoint for a CiliumIdentity.
+// The first endpoint in namespace/name order is chosen so that the output can be compared between runs.
 // key: identity number
 // value: CiliumEndpoint
 func getIdentityExample(ctx context.Context, d *dynamic.DynamicClient, id uint32) (*unstructured.Unstructured, error) {
@@ -145,8 +185,120 @@ func getIdentityExample(ctx context.Context, d *dynamic.DynamicClient, id uint32
 		return nil, nil
 	}
 
-	i := rand.IntN(len(eps))
-	ret := eps[i]
+	ret := slices.MinFunc(eps, compareEndpoint)
 	cachedIdentityExample[id] = ret
 	return ret, nil
 }
+
+func compareEndpoint(x, y *unstructured.Unstructured) int {
+	ret := strings.Compare(x.GetNamespace(), y.GetNamespace())
+	if ret == 0 {
+		ret = strings.Compare(x.GetName(), y.GetName())
+	}
+	return ret
+}
+
+type identityPeers struct {
+	Workload  string
+	Endpoints []string
+}
+
+// getIdentityPeers returns the workloads and the endpoints having a CiliumIdentity.
+func getIdentityPeers(ctx context.Context, c *kubernetes.Clientset, d *dynamic.DynamicClient, id uint32) (*identityPeers, error) {
+	k8sMutex.Lock()
+	defer k8sMutex.Unlock()
+
+	if cached, ok := cachedIdentityPeers[id]; ok {
+		return cached, nil
+	}
+
+	idEndpoints, err := getIdentityEndpoints(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	eps := slices.Clone(idEndpoints[id])
+	slices.SortFunc(eps, compareEndpoint)
+
+	ret := &identityPeers{
+		Endpoints: make([]string, len(eps)),
+	}
+	workloads := make([]string, 0)
+	for i, ep := range eps {
+		ret.Endpoints[i] = ep.GetNamespace() + "/" + ep.GetName()
+		w, err := getPodWorkloadLocked(ctx, c, ep.GetNamespace(), ep.GetName())
+		if err != nil {
+			return nil, err
+		}
+		workloads = append(workloads, w)
+	}
+	slices.Sort(workloads)
+	ret.Workload = strings.Join(slices.Compact(workloads), ",")
+
+	cachedIdentityPeers[id] = ret
+	return ret, nil
+}
+
+// getPodWorkload returns the top-level controller of a pod, such as "deploy/frontend".
+func getPodWorkload(ctx context.Context, c *kubernetes.Clientset, namespace, name string) (string, error) {
+	k8sMutex.Lock()
+	defer k8sMutex.Unlock()
+	return getPodWorkloadLocked(ctx, c, namespace, name)
+}
+
+// getPodWorkloadLocked returns the top-level controller of a pod, such as "deploy/frontend".
+// A pod or an owner deleted after its endpoint was listed is reported with the name known so far.
+func getPodWorkloadLocked(ctx context.Context, c *kubernetes.Clientset, namespace, name string) (string, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if cached, ok := cachedPodWorkloads[key]; ok {
+		return cached, nil
+	}
+
+	ret := "pod/" + name
+	pod, err := c.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cachedPodWorkloads[key] = ret
+		return ret, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		switch owner.Kind {
+		case "ReplicaSet":
+			ret = "rs/" + owner.Name
+			rs, err := c.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			switch {
+			case apierrors.IsNotFound(err):
+			case err != nil:
+				return "", err
+			default:
+				if o := metav1.GetControllerOf(rs); o != nil && o.Kind == "Deployment" {
+					ret = "deploy/" + o.Name
+				}
+			}
+		case "Job":
+			ret = "job/" + owner.Name
+			job, err := c.BatchV1().Jobs(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			switch {
+			case apierrors.IsNotFound(err):
+			case err != nil:
+				return "", err
+			default:
+				if o := metav1.GetControllerOf(job); o != nil && o.Kind == "CronJob" {
+					ret = "cronjob/" + o.Name
+				}
+			}
+		case "StatefulSet":
+			ret = "sts/" + owner.Name
+		case "DaemonSet":
+			ret = "ds/" + owner.Name
+		default:
+			ret = strings.ToLower(owner.Kind) + "/" + owner.Name
+		}
+	}
+
+	cachedPodWorkloads[key] = ret
+	return ret, nil
+}
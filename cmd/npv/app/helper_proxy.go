@@ -1,10 +1,20 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
 	"slices"
+	"strings"
 	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
 )
 
 func mapNodeReduce[T any](pods []*corev1.Pod, initFunc func() T, mapFunc func(*corev1.Pod) T, reduceFunc func(T, T) T) T {
@@ -115,3 +125,45 @@ func mergeBy[T any](x, y []T, cmp func(*T, *T) int, merge func(*T, *T) *T) []T {
 	ret = append(ret, y[j:]...)
 	return ret
 }
+
+// describeLocalIdentity describes a node-local identity by its CIDRs, or by its selectors if it is for toFQDNs rules.
+func describeLocalIdentity(ctx context.Context, client *proxy.Client, id uint32) (string, error) {
+	selectors, err := client.GetFQDNSelectorsForIdentity(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if len(selectors) > 0 {
+		return "fqdn:" + strings.Join(selectors, ","), nil
+	}
+	c, err := client.GetCIDRForIdentity(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return "cidr:" + c.String(), nil
+}
+
+// samplePolicyMaps queries the policy maps of the pods to be subtracted from a later sample.
+func samplePolicyMaps(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pods []*corev1.Pod) map[types.NamespacedName][]proxy.PolicyEntry {
+	return mapNodeReduce(pods,
+		func() map[types.NamespacedName][]proxy.PolicyEntry {
+			return make(map[types.NamespacedName][]proxy.PolicyEntry)
+		},
+		func(pod *corev1.Pod) map[types.NamespacedName][]proxy.PolicyEntry {
+			client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: failed to create Cilium client: %v\n", err)
+				return nil
+			}
+			policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return map[types.NamespacedName][]proxy.PolicyEntry{{Namespace: pod.Namespace, Name: pod.Name}: policies}
+		},
+		func(x, y map[types.NamespacedName][]proxy.PolicyEntry) map[types.NamespacedName][]proxy.PolicyEntry {
+			maps.Copy(x, y)
+			return x
+		},
+	)
+}
@@ -2,16 +2,20 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/cilium/cilium/pkg/identity"
 	"github.com/cilium/cilium/pkg/u8proto"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
@@ -20,12 +24,20 @@ import (
 	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
 )
 
+const (
+	peerDisplayExample  = "example"
+	peerDisplayWorkload = "workload"
+)
+
 var inspectOptions struct {
-	allowed   bool
-	denied    bool
-	used      bool
-	unused    bool
-	maskCIDRs bool
+	allowed     bool
+	denied      bool
+	used        bool
+	unused      bool
+	maskCIDRs   bool
+	peerDisplay string
+	expandPeers bool
+	interval    time.Duration
 }
 
 func init() {
@@ -34,17 +46,25 @@ func init() {
 	inspectCmd.Flags().BoolVar(&inspectOptions.used, "used", false, "show used-rules only")
 	inspectCmd.Flags().BoolVar(&inspectOptions.unused, "unused", false, "show unused-rules only")
 	inspectCmd.Flags().BoolVar(&inspectOptions.maskCIDRs, "mask-cidrs", false, "mask cluster-external CIDRs and unify them into public, private, and unknown")
+	inspectCmd.Flags().StringVar(&inspectOptions.peerDisplay, "peers", peerDisplayExample, "how to display peers (example, workload)")
+	inspectCmd.Flags().BoolVar(&inspectOptions.expandPeers, "expand-peers", false, "show all endpoints of each peer identity")
+	inspectCmd.Flags().DurationVar(&inspectOptions.interval, "interval", 0, "sample the policy maps twice with this interval and show rates within the window")
 	addGroupOption(inspectCmd)
 	addPodSelectorOption(inspectCmd)
+	addSampleOption(inspectCmd)
 	addWithCIDROptions(inspectCmd)
 	addDirectionOption(inspectCmd)
+	addHostOption(inspectCmd)
 	rootCmd.AddCommand(inspectCmd)
 }
 
 var inspectCmd = &cobra.Command{
 	Use:   "inspect",
 	Short: "Inspect network policies of selected pods",
-	Long:  `Inspect network policies of selected pods`,
+	Long: `Inspect network policies of selected pods
+
+With --interval, the policy maps are sampled twice and the counters are subtracted per entry.
+BYTES, REQUESTS, and AVERAGE are then computed within the window, and --used and --unused apply to the window.`,
 
 	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -60,19 +80,25 @@ var inspectCmd = &cobra.Command{
 // This command aims to show the result of "cilium bpf policy get" from a remote pod.
 // https://github.com/cilium/cilium/blob/v1.17.16/cilium-dbg/cmd/bpf_policy_get.go
 type inspectEntry struct {
-	Subject          string `json:"subject"`
-	Node             string `json:"node"`
-	Policy           string `json:"policy"`
-	Direction        string `json:"direction"`
-	Namespace        string `json:"namespace"`
-	Example          string `json:"example_endpoint"`
-	Identity         uint32 `json:"identity"`
-	WildcardProtocol bool   `json:"wildcard_protocol"`
-	WildcardPort     bool   `json:"wildcard_port"`
-	Protocol         uint8  `json:"protocol"`
-	Port             uint16 `json:"port"`
-	Bytes            uint64 `json:"bytes"`
-	Requests         uint64 `json:"requests"`
+	Subject           string   `json:"subject"`
+	Members           []string `json:"members,omitempty"`
+	Node              string   `json:"node"`
+	Policy            string   `json:"policy"`
+	Direction         string   `json:"direction"`
+	Namespace         string   `json:"namespace"`
+	Example           string   `json:"example_endpoint"`
+	Workload          string   `json:"workload,omitempty"`
+	EndpointCount     int      `json:"endpoint_count"`
+	Peers             []string `json:"peers,omitempty"`
+	Identity          uint32   `json:"identity"`
+	WildcardProtocol  bool     `json:"wildcard_protocol"`
+	WildcardPort      bool     `json:"wildcard_port"`
+	Protocol          uint8    `json:"protocol"`
+	Port              uint16   `json:"port"`
+	Bytes             uint64   `json:"bytes"`
+	Requests          uint64   `json:"requests"`
+	BytesPerSecond    float64  `json:"bytes_per_second,omitempty"`
+	RequestsPerSecond float64  `json:"requests_per_second,omitempty"`
 }
 
 func compareInspectEntry(x, y *inspectEntry) int {
@@ -111,12 +137,22 @@ func mergeInspectEntry(x, y *inspectEntry) *inspectEntry {
 		}
 	}
 
+	if len(y.Members) > 0 {
+		members := append(slices.Clone(x.Members), y.Members...)
+		slices.Sort(members)
+		x.Members = slices.Compact(members)
+	}
 	x.Bytes += y.Bytes
 	x.Requests += y.Requests
 	return x
 }
 
-func parseInspectOptions() {
+func parseInspectOptions() error {
+	switch inspectOptions.peerDisplay {
+	case peerDisplayExample, peerDisplayWorkload:
+	default:
+		return fmt.Errorf("--peers should be one of: %s, %s", peerDisplayExample, peerDisplayWorkload)
+	}
 	if !inspectOptions.allowed && !inspectOptions.denied {
 		inspectOptions.allowed = true
 		inspectOptions.denied = true
@@ -125,23 +161,82 @@ func parseInspectOptions() {
 		inspectOptions.used = true
 		inspectOptions.unused = true
 	}
+	if inspectOptions.interval < 0 {
+		return errors.New("--interval should not be negative")
+	}
+	return nil
 }
 
-func runInspectOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, filter proxy.PolicyFilter, pod *corev1.Pod) ([]inspectEntry, error) {
+// runInspectOnPod inspects the policy map of a pod.
+// When samples are given, the counters are replaced with the increase since the sample of the pod.
+func runInspectOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, filter proxy.PolicyFilter, pod *corev1.Pod, samples map[types.NamespacedName][]proxy.PolicyEntry) ([]inspectEntry, error) {
 	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
 	}
 
-	ids, err := getIdentityResourceMap(ctx, dynamicClient)
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	if samples != nil {
+		before, ok := samples[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]
+		if !ok {
+			return nil, fmt.Errorf("failed to sample the policy map of %s/%s at the beginning of the interval", pod.Namespace, pod.Name)
+		}
+		policies = proxy.SubtractPolicyMap(before, policies)
+	}
+	arr, err := makeInspectEntries(ctx, client, clientset, dynamicClient, filter, subject.GetPodSubject(pod), pod.Spec.NodeName, policies)
 	if err != nil {
 		return nil, err
 	}
 
-	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	// The entries of the pod also stand for the pods it represents with --sample
+	if members := subject.GetPodMembers(pod); len(members) > 0 {
+		names := make([]string, len(members))
+		for i, m := range members {
+			names[i] = m.String()
+		}
+		for i := range arr {
+			arr[i].Members = names
+		}
+	}
+	return arr, nil
+}
+
+// runInspectOnHost inspects the host endpoint of a node, which is used by host-networked pods.
+func runInspectOnHost(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, filter proxy.PolicyFilter, node string) ([]inspectEntry, error) {
+	client, err := proxy.CreateCiliumClientForNode(ctx, stderr, clientset, dynamicClient, node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	endpointID, err := client.GetHostEndpointID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host endpoint ID: %w", err)
+	}
+
+	policies, err := client.QueryPolicyMapByID(ctx, endpointID)
 	if err != nil {
 		return nil, err
 	}
+	if inspectOptions.interval > 0 {
+		time.Sleep(inspectOptions.interval)
+		after, err := client.QueryPolicyMapByID(ctx, endpointID)
+		if err != nil {
+			return nil, err
+		}
+		policies = proxy.SubtractPolicyMap(policies, after)
+	}
+	return makeInspectEntries(ctx, client, clientset, dynamicClient, filter, node, node, policies)
+}
+
+func makeInspectEntries(ctx context.Context, client *proxy.Client, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, filter proxy.PolicyFilter, sub, node string, policies []proxy.PolicyEntry) ([]inspectEntry, error) {
+	ids, err := getIdentityResourceMap(ctx, dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+
 	if policies, err = proxy.FilterPolicyMap(ctx, client, policies, filter); err != nil {
 		return nil, err
 	}
@@ -149,8 +244,8 @@ func runInspectOnPod(ctx context.Context, stderr io.Writer, clientset *kubernete
 	arr := make([]inspectEntry, len(policies))
 	for i, p := range policies {
 		var entry inspectEntry
-		entry.Subject = subject.GetPodSubject(pod.Namespace, pod.Name)
-		entry.Node = pod.Spec.NodeName
+		entry.Subject = sub
+		entry.Node = node
 		if p.IsDeny() {
 			entry.Policy = policyDeny
 		} else {
@@ -184,28 +279,51 @@ func runInspectOnPod(ctx context.Context, stderr io.Writer, clientset *kubernete
 			if idObj.IsReservedIdentity() {
 				entry.Example = "reserved:" + idObj.String()
 			} else if idObj.HasLocalScope() {
-				c, err := client.GetCIDRForIdentity(ctx, p.Key.Identity)
+				// Identities for toFQDNs rules may have no CIDRs, so they are shown by their selectors
+				selectors, err := client.GetFQDNSelectorsForIdentity(ctx, p.Key.Identity)
 				if err != nil {
 					return nil, err
 				}
-				if inspectOptions.maskCIDRs {
-					private := cidr.PrivateCIDRSet.Overlaps(*c)
-					public := cidr.PublicCIDRSet.Overlaps(*c)
-					var expr string
-					switch {
-					case private && public:
-						expr = "unknown"
-					case public:
-						expr = "public"
-					case private:
-						expr = "private"
-					default:
-						expr = "none"
-					}
-					entry.Identity = uint32(identity.ReservedIdentityWorld)
-					entry.Example = fmt.Sprintf("cidr:%s", expr)
+				if len(selectors) > 0 {
+					entry.Example = "fqdn:" + strings.Join(selectors, ",")
 				} else {
-					entry.Example = "cidr:" + c.String()
+					c, err := client.GetCIDRForIdentity(ctx, p.Key.Identity)
+					if err != nil {
+						return nil, err
+					}
+					if inspectOptions.maskCIDRs {
+						private := cidr.PrivateCIDRSet.Overlaps(*c)
+						public := cidr.PublicCIDRSet.Overlaps(*c)
+						var expr string
+						switch {
+						case private && public:
+							expr = "unknown"
+						case public:
+							expr = "public"
+						case private:
+							expr = "private"
+						default:
+							expr = "none"
+						}
+						entry.Identity = uint32(identity.ReservedIdentityWorld)
+						entry.Example = fmt.Sprintf("cidr:%s", expr)
+					} else {
+						entry.Example = "cidr:" + c.String()
+					}
+				}
+			}
+		}
+		if inspectOptions.peerDisplay == peerDisplayWorkload || inspectOptions.expandPeers {
+			entry.Workload = entry.Example
+			if example != nil {
+				peers, err := getIdentityPeers(ctx, clientset, dynamicClient, p.Key.Identity)
+				if err != nil {
+					return nil, err
+				}
+				entry.Workload = peers.Workload
+				entry.EndpointCount = len(peers.Endpoints)
+				if inspectOptions.expandPeers {
+					entry.Peers = peers.Endpoints
 				}
 			}
 		}
@@ -223,7 +341,9 @@ func runInspectOnPod(ctx context.Context, stderr io.Writer, clientset *kubernete
 }
 
 func runInspect(ctx context.Context, stdout, stderr io.Writer, name string) error {
-	parseInspectOptions()
+	if err := parseInspectOptions(); err != nil {
+		return err
+	}
 	basicFilter := proxy.MakeBasicFilter(
 		policyOptions.ingress, policyOptions.egress,
 		inspectOptions.allowed, inspectOptions.denied,
@@ -240,31 +360,50 @@ func runInspect(ctx context.Context, stdout, stderr io.Writer, name string) erro
 		return err
 	}
 
-	pods, err := subject.ListSubjectPods(ctx, clientset, name)
-	if err != nil {
-		return err
+	var arr []inspectEntry
+	printSubject := subject.ShouldPrintSubject(name)
+	if commonOptions.host != "" {
+		if name != "" {
+			return errors.New("pod name should not be specified with --host")
+		}
+		arr, err = runInspectOnHost(ctx, stderr, clientset, dynamicClient, filter, commonOptions.host)
+		if err != nil {
+			return err
+		}
+		printSubject = false
+	} else {
+		arr, err = runInspectOnPods(ctx, stderr, clientset, dynamicClient, filter, name)
+		if err != nil {
+			return err
+		}
 	}
 
-	arr := mapNodeReduce(pods,
-		func() []inspectEntry {
-			return make([]inspectEntry, 0)
-		},
-		func(pod *corev1.Pod) []inspectEntry {
-			result, err := runInspectOnPod(ctx, stderr, clientset, dynamicClient, filter, pod)
-			if err != nil {
-				fmt.Fprintf(stderr, "Warning: %v\n", err)
-				return nil
-			}
-			return result
-		},
-		func(x, y []inspectEntry) []inspectEntry {
-			return mergeBy(x, y, compareInspectEntry, mergeInspectEntry)
-		},
-	)
-
+	sampled := subject.GetSelectorConfig().Sample != subject.SampleNone
 	subHeader := []string{"SUBJECT", "|"}
-	header := []string{"POLICY", "DIRECTION", "|", "IDENTITY", "NAMESPACE", "EXAMPLE-ENDPOINT", "|", "PROTOCOL", "PORT", "|", "BYTES:", "REQUESTS:", "AVERAGE:"}
-	if subject.ShouldPrintSubject(name) {
+	if sampled {
+		subHeader = []string{"SUBJECT", "MEMBERS:", "|"}
+		printSubject = true
+	}
+	header := []string{"POLICY", "DIRECTION", "|", "IDENTITY", "NAMESPACE"}
+	switch inspectOptions.peerDisplay {
+	case peerDisplayExample:
+		header = append(header, "EXAMPLE-ENDPOINT")
+	case peerDisplayWorkload:
+		header = append(header, "WORKLOAD", "ENDPOINTS:")
+	}
+	if inspectOptions.expandPeers {
+		header = append(header, "PEERS")
+	}
+	if inspectOptions.interval > 0 {
+		for i := range arr {
+			arr[i].BytesPerSecond = float64(arr[i].Bytes) / inspectOptions.interval.Seconds()
+			arr[i].RequestsPerSecond = float64(arr[i].Requests) / inspectOptions.interval.Seconds()
+		}
+		header = append(header, "|", "PROTOCOL", "PORT", "|", "BYTES/S:", "REQUESTS/S:", "AVERAGE:")
+	} else {
+		header = append(header, "|", "PROTOCOL", "PORT", "|", "BYTES:", "REQUESTS:", "AVERAGE:")
+	}
+	if printSubject {
 		header = append(subHeader, header...)
 	}
 	return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
@@ -286,11 +425,63 @@ func runInspect(ctx context.Context, stdout, stderr io.Writer, name string) erro
 			}
 		}
 		avg := fmt.Sprintf("%.1f", computeAverage(p.Bytes, p.Requests))
-		values := []any{p.Policy, p.Direction, "|", p.Identity, p.Namespace, example, "|", protocol, port, "|", formatWithUnits(p.Bytes), formatWithUnits(p.Requests), avg}
-		if subject.ShouldPrintSubject(name) {
+		values := []any{p.Policy, p.Direction, "|", p.Identity, p.Namespace}
+		switch inspectOptions.peerDisplay {
+		case peerDisplayExample:
+			values = append(values, example)
+		case peerDisplayWorkload:
+			values = append(values, p.Workload, p.EndpointCount)
+		}
+		if inspectOptions.expandPeers {
+			values = append(values, p.Peers)
+		}
+		if inspectOptions.interval > 0 {
+			values = append(values, "|", protocol, port, "|", fmt.Sprintf("%.1f", p.BytesPerSecond), fmt.Sprintf("%.1f", p.RequestsPerSecond), avg)
+		} else {
+			values = append(values, "|", protocol, port, "|", formatWithUnits(p.Bytes), formatWithUnits(p.Requests), avg)
+		}
+		if printSubject {
 			subValues := []any{p.Subject, "|"}
+			if sampled {
+				subValues = []any{p.Subject, len(p.Members), "|"}
+			}
 			values = append(subValues, values...)
 		}
 		return values
 	})
 }
+
+func runInspectOnPods(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, filter proxy.PolicyFilter, name string) ([]inspectEntry, error) {
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return nil, err
+	}
+
+	// Sample all pods first and wait only once, so that the windows of the pods are aligned
+	var samples map[types.NamespacedName][]proxy.PolicyEntry
+	if inspectOptions.interval > 0 {
+		samples = samplePolicyMaps(ctx, stderr, clientset, dynamicClient, pods)
+		time.Sleep(inspectOptions.interval)
+	}
+
+	arr := mapNodeReduce(pods,
+		func() []inspectEntry {
+			return make([]inspectEntry, 0)
+		},
+		func(pod *corev1.Pod) []inspectEntry {
+			result, err := runInspectOnPod(ctx, stderr, clientset, dynamicClient, filter, pod, samples)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return result
+		},
+		func(x, y []inspectEntry) []inspectEntry {
+			return mergeBy(x, y, compareInspectEntry, mergeInspectEntry)
+		},
+	)
+	return arr, nil
+}
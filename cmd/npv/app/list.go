@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
@@ -9,11 +10,12 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/cilium/cilium/api/v1/client/endpoint"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/yaml"
@@ -25,13 +27,16 @@ import (
 
 var listOptions struct {
 	manifests bool
+	rules     bool
 }
 
 func init() {
 	addGroupOption(listCmd)
 	addPodSelectorOption(listCmd)
 	addDirectionOption(listCmd)
+	addHostOption(listCmd)
 	listCmd.Flags().BoolVarP(&listOptions.manifests, "manifests", "m", false, "show policy manifests")
+	listCmd.Flags().BoolVar(&listOptions.rules, "rules", false, "show realized rules of each policy with the identities selected by their peer selectors")
 	rootCmd.AddCommand(listCmd)
 }
 
@@ -100,8 +105,6 @@ func parseListEntry(subject, direction string, input []string) listEntry {
 }
 
 func runListOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) ([]listEntry, error) {
-	policySet := make(map[listEntry]any)
-
 	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
@@ -111,12 +114,36 @@ func runListOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.C
 	if err != nil {
 		return nil, fmt.Errorf("failed to get endpoint info: %w", err)
 	}
+	return makeListEntries(subject.GetPodSubject(pod), response), nil
+}
+
+// runListOnHost lists the host policies applied to the host endpoint of a node.
+func runListOnHost(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, node string) ([]listEntry, error) {
+	client, err := proxy.CreateCiliumClientForNode(ctx, stderr, clientset, dynamicClient, node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	endpointID, err := client.GetHostEndpointID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host endpoint ID: %w", err)
+	}
+
+	response, err := client.GetEndpointResponseByID(ctx, endpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint info: %w", err)
+	}
+	return makeListEntries(node, response), nil
+}
+
+func makeListEntries(sub string, response *endpoint.GetEndpointIDOK) []listEntry {
+	policySet := make(map[listEntry]any)
 
 	if policyOptions.ingress {
 		ingressRules := response.Payload.Status.Policy.Realized.L4.Ingress
 		for _, rule := range ingressRules {
 			for _, r := range rule.DerivedFromRules {
-				entry := parseListEntry(subject.GetPodSubject(pod.Namespace, pod.Name), directionIngress, r)
+				entry := parseListEntry(sub, directionIngress, r)
 				policySet[entry] = struct{}{}
 			}
 		}
@@ -125,7 +152,7 @@ func runListOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.C
 		egressRules := response.Payload.Status.Policy.Realized.L4.Egress
 		for _, rule := range egressRules {
 			for _, r := range rule.DerivedFromRules {
-				entry := parseListEntry(subject.GetPodSubject(pod.Namespace, pod.Name), directionEgress, r)
+				entry := parseListEntry(sub, directionEgress, r)
 				policySet[entry] = struct{}{}
 			}
 		}
@@ -133,7 +160,7 @@ func runListOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.C
 
 	policyList := slices.Collect(maps.Keys(policySet))
 	sort.Slice(policyList, func(i, j int) bool { return compareListEntry(&policyList[i], &policyList[j]) < 0 })
-	return policyList, nil
+	return policyList
 }
 
 func runList(ctx context.Context, stdout, stderr io.Writer, name string) error {
@@ -142,10 +169,75 @@ func runList(ctx context.Context, stdout, stderr io.Writer, name string) error {
 		return fmt.Errorf("failed to create k8s clients: %w", err)
 	}
 
-	pods, err := subject.ListSubjectPods(ctx, clientset, name)
+	if listOptions.manifests && listOptions.rules {
+		return errors.New("--manifests and --rules cannot be used together")
+	}
+	if commonOptions.host != "" && name != "" {
+		return errors.New("pod name should not be specified with --host")
+	}
+	if listOptions.rules {
+		return runListRules(ctx, stdout, stderr, clientset, dynamicClient, name)
+	}
+
+	var arr []listEntry
+	printSubject := subject.ShouldPrintSubject(name)
+	if commonOptions.host != "" {
+		arr, err = runListOnHost(ctx, stderr, clientset, dynamicClient, commonOptions.host)
+		if err != nil {
+			return err
+		}
+		printSubject = false
+	} else {
+		arr, err = runListOnPods(ctx, stderr, clientset, dynamicClient, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if listOptions.manifests {
+		return listPolicyManifests(ctx, stdout, stderr, dynamicClient, arr)
+	}
+
+	subHeader := []string{"SUBJECT", "|"}
+	header := []string{"DIRECTION", "|", "KIND", "NAMESPACE", "NAME"}
+	if printSubject {
+		header = append(subHeader, header...)
+	}
+	return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
+		p := arr[index]
+		subValues := []any{p.Subject, "|"}
+		values := []any{p.Direction, "|", p.Kind, p.Namespace, p.Name}
+		if printSubject {
+			values = append(subValues, values...)
+		}
+		return values
+	})
+}
+
+func runListRules(ctx context.Context, stdout, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, name string) error {
+	if commonOptions.host != "" {
+		arr, err := runListRulesOnHost(ctx, stderr, clientset, dynamicClient, commonOptions.host)
+		if err != nil {
+			return err
+		}
+		return writeListRules(stdout, arr, false)
+	}
+
+	arr, err := runListRulesOnPods(ctx, stderr, clientset, dynamicClient, name)
 	if err != nil {
 		return err
 	}
+	return writeListRules(stdout, arr, subject.ShouldPrintSubject(name))
+}
+
+func runListOnPods(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, name string) ([]listEntry, error) {
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return nil, err
+	}
 
 	// The same rule appears multiple times in the response, so we need to dedup it
 	arr := mapNodeReduce(pods,
@@ -164,39 +256,42 @@ func runList(ctx context.Context, stdout, stderr io.Writer, name string) error {
 			return mergeBy(x, y, compareListEntry, mergeListEntry)
 		},
 	)
+	return arr, nil
+}
 
-	if listOptions.manifests {
-		return listPolicyManifests(ctx, stdout, dynamicClient, arr)
-	}
+// policyKinds maps the kinds recorded in io.cilium.k8s.policy.derived-from to their resources.
+var policyKinds = map[string]struct {
+	resource   schema.GroupVersionResource
+	namespaced bool
+}{
+	"CiliumNetworkPolicy":            {gvr.NetworkPolicy, true},
+	"CiliumClusterwideNetworkPolicy": {gvr.ClusterwideNetworkPolicy, false},
+	"NetworkPolicy":                  {gvr.K8sNetworkPolicy, true},
+	"AdminNetworkPolicy":             {gvr.AdminNetworkPolicy, false},
+	"BaselineAdminNetworkPolicy":     {gvr.BaselineAdminNetworkPolicy, false},
+}
 
-	subHeader := []string{"SUBJECT", "|"}
-	header := []string{"DIRECTION", "|", "KIND", "NAMESPACE", "NAME"}
-	if subject.ShouldPrintSubject(name) {
-		header = append(subHeader, header...)
+func getPolicyManifest(ctx context.Context, dynamicClient *dynamic.DynamicClient, p *listEntry) (*unstructured.Unstructured, error) {
+	kind := policyKinds[p.Kind]
+	if kind.namespaced {
+		return dynamicClient.Resource(kind.resource).Namespace(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
 	}
-	return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
-		p := arr[index]
-		subValues := []any{p.Subject, "|"}
-		values := []any{p.Direction, "|", p.Kind, p.Namespace, p.Name}
-		if subject.ShouldPrintSubject(name) {
-			values = append(subValues, values...)
-		}
-		return values
-	})
+	return dynamicClient.Resource(kind.resource).Get(ctx, p.Name, metav1.GetOptions{})
 }
 
-func listPolicyManifests(ctx context.Context, w io.Writer, dynamicClient *dynamic.DynamicClient, policyList []listEntry) error {
+func listPolicyManifests(ctx context.Context, w, stderr io.Writer, dynamicClient *dynamic.DynamicClient, policyList []listEntry) error {
 	// remove direction info and sort again
 	for i := range policyList {
 		policyList[i].Direction = ""
 	}
 	sort.Slice(policyList, func(i, j int) bool { return compareListEntry(&policyList[i], &policyList[j]) < 0 })
 
-	var previous types.NamespacedName
+	var previous listEntry
 	first := true
 	for _, p := range policyList {
 		// a same policy may appear twice from egress and ingress rules, so we need to dedup them
-		next := types.NamespacedName{
+		next := listEntry{
+			Kind:      p.Kind,
 			Namespace: p.Namespace,
 			Name:      p.Name,
 		}
@@ -205,6 +300,11 @@ func listPolicyManifests(ctx context.Context, w io.Writer, dynamicClient *dynami
 		}
 		previous = next
 
+		if _, ok := policyKinds[p.Kind]; !ok {
+			fmt.Fprintf(stderr, "Warning: policy %s/%s is derived from unsupported kind %s\n", p.Namespace, p.Name, p.Kind)
+			continue
+		}
+
 		if !first {
 			if _, err := fmt.Fprintln(w, "---"); err != nil {
 				return err
@@ -212,20 +312,9 @@ func listPolicyManifests(ctx context.Context, w io.Writer, dynamicClient *dynami
 		}
 		first = false
 
-		isCNP := p.Kind == "CiliumNetworkPolicy"
-		var resource *unstructured.Unstructured
-		if isCNP {
-			cnp, err := dynamicClient.Resource(gvr.NetworkPolicy).Namespace(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
-			if err != nil {
-				return err
-			}
-			resource = cnp
-		} else {
-			ccnp, err := dynamicClient.Resource(gvr.ClusterwideNetworkPolicy).Get(ctx, p.Name, metav1.GetOptions{})
-			if err != nil {
-				return err
-			}
-			resource = ccnp
+		resource, err := getPolicyManifest(ctx, dynamicClient, &p)
+		if err != nil {
+			return err
 		}
 		unstructured.RemoveNestedField(resource.Object, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
 		unstructured.RemoveNestedField(resource.Object, "metadata", "creationTimestamp")
@@ -0,0 +1,321 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/cilium/api/v1/client/endpoint"
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/policy/api"
+	"github.com/cilium/cilium/pkg/u8proto"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+// realizedL4Filter is a subset of L4Filter in Cilium, which is serialized into the rule of the realized policy.
+// https://github.com/cilium/cilium/blob/v1.17.16/pkg/policy/l4.go
+type realizedL4Filter struct {
+	Port     uint16                               `json:"port"`
+	EndPort  uint16                               `json:"endPort"`
+	PortName string                               `json:"port-name"`
+	Protocol string                               `json:"protocol"`
+	L7Rules  []map[string]*realizedSelectorPolicy `json:"l7-rules"`
+}
+
+// realizedSelectorPolicy is a subset of PerSelectorPolicy in Cilium.
+// A nil policy means that the selector is allowed without L7 rules.
+type realizedSelectorPolicy struct {
+	api.L7Rules
+	Authentication *api.Authentication `json:"auth"`
+	IsDeny         bool                `json:"IsDeny"`
+}
+
+func (f *realizedL4Filter) matches(protocol uint8, port uint16) bool {
+	if f.Protocol != "ANY" && f.Protocol != u8proto.U8proto(protocol).String() {
+		return false
+	}
+	switch {
+	case f.Port == 0 || port == 0:
+		return true
+	case f.EndPort > f.Port:
+		return f.Port <= port && port <= f.EndPort
+	default:
+		return f.Port == port
+	}
+}
+
+// realizedRule is a realized L4 filter expanded per peer selector.
+type realizedRule struct {
+	Direction   string
+	Filter      *realizedL4Filter
+	Selector    string
+	Policy      *realizedSelectorPolicy
+	DerivedFrom [][]string
+}
+
+func (r *realizedRule) IsDeny() bool {
+	return r.Policy != nil && r.Policy.IsDeny
+}
+
+// expandRealizedRules expands the realized rules of an endpoint into one rule per selector.
+// Each rule keeps the labels of the policies it was derived from.
+func expandRealizedRules(l4 *models.L4Policy, ingress, egress bool) []realizedRule {
+	ret := make([]realizedRule, 0)
+	if l4 == nil {
+		return ret
+	}
+	for _, d := range []struct {
+		direction string
+		enabled   bool
+		rules     []*models.PolicyRule
+	}{
+		{directionIngress, ingress, l4.Ingress},
+		{directionEgress, egress, l4.Egress},
+	} {
+		if !d.enabled {
+			continue
+		}
+		for _, rule := range d.rules {
+			var filter realizedL4Filter
+			if err := json.Unmarshal([]byte(rule.Rule), &filter); err != nil {
+				continue
+			}
+			for _, l7 := range filter.L7Rules {
+				for selector, p := range l7 {
+					derived, ok := rule.RulesBySelector[selector]
+					if !ok {
+						derived = rule.DerivedFromRules
+					}
+					ret = append(ret, realizedRule{
+						Direction:   d.direction,
+						Filter:      &filter,
+						Selector:    selector,
+						Policy:      p,
+						DerivedFrom: derived,
+					})
+				}
+			}
+		}
+	}
+	return ret
+}
+
+func (f *realizedL4Filter) portString() string {
+	switch {
+	case f.PortName != "":
+		return f.PortName
+	case f.Port == 0:
+		return "ANY"
+	case f.EndPort > f.Port:
+		return fmt.Sprintf("%d-%d", f.Port, f.EndPort)
+	default:
+		return strconv.Itoa(int(f.Port))
+	}
+}
+
+func formatL7Rules(r *api.L7Rules) []string {
+	ret := make([]string, 0)
+	for _, h := range r.HTTP {
+		method := cmp.Or(h.Method, "*")
+		path := cmp.Or(h.Path, "/*")
+		if h.Host != "" {
+			path = h.Host + path
+		}
+		ret = append(ret, fmt.Sprintf("HTTP %s %s", method, path))
+	}
+	for _, k := range r.Kafka {
+		ret = append(ret, fmt.Sprintf("Kafka %s %s", cmp.Or(k.Role, k.APIKey, "*"), cmp.Or(k.Topic, "*")))
+	}
+	for _, d := range r.DNS {
+		ret = append(ret, fmt.Sprintf("DNS %s", cmp.Or(d.MatchName, d.MatchPattern)))
+	}
+	for range r.L7 {
+		ret = append(ret, r.L7Proto)
+	}
+	return ret
+}
+
+type listRuleEntry struct {
+	listEntry
+	Policy     string   `json:"policy"`
+	Protocol   string   `json:"protocol"`
+	Port       string   `json:"port"`
+	Selector   string   `json:"selector"`
+	Identities []uint32 `json:"identities"`
+	L7Rules    []string `json:"l7_rules,omitempty"`
+	Auth       string   `json:"auth,omitempty"`
+}
+
+func compareListRuleEntry(x, y *listRuleEntry) int {
+	if ret := compareListEntry(&x.listEntry, &y.listEntry); ret != 0 {
+		return ret
+	}
+	// List Deny first
+	if ret := -strings.Compare(x.Policy, y.Policy); ret != 0 {
+		return ret
+	}
+	if ret := strings.Compare(x.Protocol, y.Protocol); ret != 0 {
+		return ret
+	}
+	if ret := strings.Compare(x.Port, y.Port); ret != 0 {
+		return ret
+	}
+	return strings.Compare(x.Selector, y.Selector)
+}
+
+func mergeListRuleEntry(x, y *listRuleEntry) *listRuleEntry {
+	return x
+}
+
+// makeListRuleEntries expands the realized L4 filters into one entry per policy, port and selector.
+func makeListRuleEntries(sub string, response *endpoint.GetEndpointIDOK, selectors map[string][]uint32) []listRuleEntry {
+	ret := make([]listRuleEntry, 0)
+	for _, r := range expandRealizedRules(response.Payload.Status.Policy.Realized.L4, policyOptions.ingress, policyOptions.egress) {
+		entry := listRuleEntry{
+			Policy:     policyAllow,
+			Protocol:   r.Filter.Protocol,
+			Port:       r.Filter.portString(),
+			Selector:   r.Selector,
+			Identities: selectors[r.Selector],
+			L7Rules:    make([]string, 0),
+		}
+		if r.IsDeny() {
+			entry.Policy = policyDeny
+		}
+		if r.Policy != nil {
+			entry.L7Rules = formatL7Rules(&r.Policy.L7Rules)
+			if r.Policy.Authentication != nil {
+				entry.Auth = string(r.Policy.Authentication.Mode)
+			}
+		}
+		for _, d := range r.DerivedFrom {
+			entry.listEntry = parseListEntry(sub, r.Direction, d)
+			ret = append(ret, entry)
+		}
+	}
+
+	slices.SortFunc(ret, func(x, y listRuleEntry) int { return compareListRuleEntry(&x, &y) })
+	return slices.CompactFunc(ret, func(x, y listRuleEntry) bool { return compareListRuleEntry(&x, &y) == 0 })
+}
+
+func runListRulesOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) ([]listRuleEntry, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	response, err := client.GetEndpointResponse(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint info: %w", err)
+	}
+
+	selectors, err := client.GetSelectorIdentities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get selector identities: %w", err)
+	}
+	return makeListRuleEntries(subject.GetPodSubject(pod), response, selectors), nil
+}
+
+func runListRulesOnHost(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, node string) ([]listRuleEntry, error) {
+	client, err := proxy.CreateCiliumClientForNode(ctx, stderr, clientset, dynamicClient, node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	endpointID, err := client.GetHostEndpointID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host endpoint ID: %w", err)
+	}
+
+	response, err := client.GetEndpointResponseByID(ctx, endpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint info: %w", err)
+	}
+
+	selectors, err := client.GetSelectorIdentities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get selector identities: %w", err)
+	}
+	return makeListRuleEntries(node, response, selectors), nil
+}
+
+func runListRulesOnPods(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, name string) ([]listRuleEntry, error) {
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return nil, err
+	}
+
+	arr := mapNodeReduce(pods,
+		func() []listRuleEntry {
+			return make([]listRuleEntry, 0)
+		},
+		func(pod *corev1.Pod) []listRuleEntry {
+			rules, err := runListRulesOnPod(ctx, stderr, clientset, dynamicClient, pod)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return rules
+		},
+		func(x, y []listRuleEntry) []listRuleEntry {
+			return mergeBy(x, y, compareListRuleEntry, mergeListRuleEntry)
+		},
+	)
+	return arr, nil
+}
+
+// maxListRuleIdentities is the number of identities shown in a table cell.
+// Selectors such as toEntities: world may match thousands of CIDR identities.
+const maxListRuleIdentities = 5
+
+func writeListRules(w io.Writer, arr []listRuleEntry, printSubject bool) error {
+	subHeader := []string{"SUBJECT", "|"}
+	header := []string{"DIRECTION", "POLICY", "|", "KIND", "NAMESPACE", "NAME", "|", "PROTOCOL", "PORT", "|", "SELECTOR", "IDENTITIES", "|", "L7", "AUTH"}
+	if printSubject {
+		header = append(subHeader, header...)
+	}
+	return writeSimpleOrJson(w, arr, header, len(arr), func(index int) []any {
+		p := arr[index]
+
+		ids := make([]string, 0, maxListRuleIdentities+1)
+		for i, id := range p.Identities {
+			if i == maxListRuleIdentities {
+				ids = append(ids, fmt.Sprintf("...(%d)", len(p.Identities)))
+				break
+			}
+			ids = append(ids, strconv.Itoa(int(id)))
+		}
+		identities := strings.Join(ids, ",")
+		if identities == "" {
+			identities = "-"
+		}
+		l7 := p.L7Rules
+		if len(l7) == 0 {
+			l7 = []string{"-"}
+		}
+		auth := p.Auth
+		if auth == "" {
+			auth = "-"
+		}
+
+		subValues := []any{p.Subject, "|"}
+		values := []any{p.Direction, p.Policy, "|", p.Kind, p.Namespace, p.Name, "|", p.Protocol, p.Port, "|", p.Selector, identities, "|", l7, auth}
+		if printSubject {
+			values = append(subValues, values...)
+		}
+		return values
+	})
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"sigs.k8s.io/yaml"
 
 	"github.com/cybozu-go/network-policy-viewer/pkg/gvk"
@@ -85,47 +87,65 @@ func runManifestGenerate(ctx context.Context, w io.Writer) error {
 		return err
 	}
 
-	subIdentity, err := getPodIdentity(ctx, dynamicClient, sub.Namespace, sub.Name)
+	manifest, err := makePolicyManifest(ctx, dynamicClient, sub, obj, egress, deny, manifestGenerateOptions.name, nil)
 	if err != nil {
 		return err
 	}
 
-	subResource, err := dynamicClient.Resource(gvr.Identity).Get(ctx, strconv.Itoa(int(subIdentity)), metav1.GetOptions{})
+	data, err := yaml.Marshal(manifest.Object)
 	if err != nil {
 		return err
 	}
+	if _, err := fmt.Fprintf(w, "%s", string(data)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// makePolicyManifest builds a CiliumNetworkPolicy which selects sub and allows or denies the traffic to/from obj.
+// If toPorts is not nil, the rule is limited to the ports.
+func makePolicyManifest(ctx context.Context, dynamicClient *dynamic.DynamicClient, sub, obj types.NamespacedName, egress, deny bool, name string, toPorts []any) (*unstructured.Unstructured, error) {
+	subIdentity, err := getPodIdentity(ctx, dynamicClient, sub.Namespace, sub.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	subResource, err := dynamicClient.Resource(gvr.Identity).Get(ctx, strconv.Itoa(int(subIdentity)), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
 
 	subLabels, ok, err := unstructured.NestedStringMap(subResource.Object, "security-labels")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !ok {
-		return fmt.Errorf("pod %s/%s is not assigned security labels", sub.Namespace, sub.Name)
+		return nil, fmt.Errorf("pod %s/%s is not assigned security labels", sub.Namespace, sub.Name)
 	}
 
 	objIdentity, err := getPodIdentity(ctx, dynamicClient, obj.Namespace, obj.Name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	objResource, err := dynamicClient.Resource(gvr.Identity).Get(ctx, strconv.Itoa(int(objIdentity)), metav1.GetOptions{})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	objLabels, ok, err := unstructured.NestedStringMap(objResource.Object, "security-labels")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !ok {
-		return fmt.Errorf("pod %s/%s is not assigned security labels", obj.Namespace, obj.Name)
+		return nil, fmt.Errorf("pod %s/%s is not assigned security labels", obj.Namespace, obj.Name)
 	}
 
-	policyName := manifestGenerateOptions.name
+	policyName := name
 	if policyName == "" {
 		direction := "egress"
 		policy := "allow"
-		if ingress {
+		if !egress {
 			direction = "ingress"
 		}
 		if deny {
@@ -140,7 +160,7 @@ func runManifestGenerate(ctx context.Context, w io.Writer) error {
 	manifest.SetName(policyName)
 	err = unstructured.SetNestedStringMap(manifest.Object, subLabels, "spec", "endpointSelector", "matchLabels")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	objMap := make(map[string]any)
@@ -150,39 +170,33 @@ func runManifestGenerate(ctx context.Context, w io.Writer) error {
 
 	var section, field string
 	switch {
-	case egress && allow:
+	case egress && !deny:
 		section = "egress"
 		field = "toEndpoints"
 	case egress && deny:
 		section = "egressDeny"
 		field = "toEndpoints"
-	case ingress && allow:
+	case !egress && !deny:
 		section = "ingress"
 		field = "fromEndpoints"
-	case ingress && deny:
+	case !egress && deny:
 		section = "ingressDeny"
 		field = "fromEndpoints"
 	}
 
-	err = unstructured.SetNestedField(manifest.Object, []any{
-		map[string]any{
-			field: []any{
-				map[string]any{
-					"matchLabels": objMap,
-				},
+	rule := map[string]any{
+		field: []any{
+			map[string]any{
+				"matchLabels": objMap,
 			},
 		},
-	}, "spec", section)
-	if err != nil {
-		return err
 	}
-
-	data, err := yaml.Marshal(manifest.Object)
-	if err != nil {
-		return err
+	if toPorts != nil {
+		rule["toPorts"] = toPorts
 	}
-	if _, err := fmt.Fprintf(w, "%s", string(data)); err != nil {
-		return err
+	err = unstructured.SetNestedField(manifest.Object, []any{rule}, "spec", section)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return &manifest, nil
 }
@@ -0,0 +1,268 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	k8sConst "github.com/cilium/cilium/pkg/k8s/apis/cilium.io"
+	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	slim_metav1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
+	"github.com/cilium/cilium/pkg/labels"
+	"github.com/cilium/cilium/pkg/policy/api"
+	"github.com/spf13/cobra"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+}
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Inspect network policies",
+	Long:  `Inspect network policies`,
+}
+
+// policyKindAliases maps the lower-cased kinds and their short names to the kinds in policyKinds.
+var policyKindAliases = map[string]string{
+	"cnp":                            "CiliumNetworkPolicy",
+	"ciliumnetworkpolicy":            "CiliumNetworkPolicy",
+	"ccnp":                           "CiliumClusterwideNetworkPolicy",
+	"ciliumclusterwidenetworkpolicy": "CiliumClusterwideNetworkPolicy",
+	"netpol":                         "NetworkPolicy",
+	"networkpolicy":                  "NetworkPolicy",
+	"anp":                            "AdminNetworkPolicy",
+	"adminnetworkpolicy":             "AdminNetworkPolicy",
+	"banp":                           "BaselineAdminNetworkPolicy",
+	"baselineadminnetworkpolicy":     "BaselineAdminNetworkPolicy",
+}
+
+type policyRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+func (r policyRef) String() string {
+	if r.Namespace == "-" {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+func comparePolicyRef(x, y *policyRef) int {
+	ret := strings.Compare(x.Kind, y.Kind)
+	if ret == 0 {
+		ret = strings.Compare(x.Namespace, y.Namespace)
+	}
+	if ret == 0 {
+		ret = strings.Compare(x.Name, y.Name)
+	}
+	return ret
+}
+
+func makePolicyRef(e *listEntry) policyRef {
+	return policyRef{Kind: e.Kind, Namespace: e.Namespace, Name: e.Name}
+}
+
+// parsePolicyRef parses "KIND/NAMESPACE/NAME" for namespaced kinds and "KIND/NAME" for cluster-scoped kinds.
+func parsePolicyRef(s string) (policyRef, error) {
+	parts := strings.Split(s, "/")
+	kind, ok := policyKindAliases[strings.ToLower(parts[0])]
+	if !ok {
+		return policyRef{}, fmt.Errorf("unknown policy kind %s: should be one of %s", parts[0], strings.Join(slices.Sorted(maps.Keys(policyKindAliases)), ", "))
+	}
+
+	if policyKinds[kind].namespaced {
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return policyRef{}, fmt.Errorf("%s should be specified as KIND/NAMESPACE/NAME", kind)
+		}
+		return policyRef{Kind: kind, Namespace: parts[1], Name: parts[2]}, nil
+	}
+	if len(parts) != 2 || parts[1] == "" {
+		return policyRef{}, fmt.Errorf("%s should be specified as KIND/NAME", kind)
+	}
+	return policyRef{Kind: kind, Namespace: "-", Name: parts[1]}, nil
+}
+
+func getPolicyResource(ctx context.Context, dynamicClient *dynamic.DynamicClient, ref policyRef) (*unstructured.Unstructured, error) {
+	return getPolicyManifest(ctx, dynamicClient, &listEntry{Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name})
+}
+
+// parseCiliumPolicyRules returns the rules of a CiliumNetworkPolicy or a CiliumClusterwideNetworkPolicy.
+func parseCiliumPolicyRules(ref policyRef, resource *unstructured.Unstructured) (api.Rules, error) {
+	switch ref.Kind {
+	case "CiliumNetworkPolicy":
+		var cnp ciliumv2.CiliumNetworkPolicy
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, &cnp); err != nil {
+			return nil, err
+		}
+		return cnp.Parse()
+
+	case "CiliumClusterwideNetworkPolicy":
+		var ccnp ciliumv2.CiliumClusterwideNetworkPolicy
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, &ccnp); err != nil {
+			return nil, err
+		}
+		return ccnp.Parse()
+
+	default:
+		return nil, fmt.Errorf("%s is not a Cilium policy", ref.Kind)
+	}
+}
+
+// parsePolicySelectors returns the endpoint selectors of a policy in the same form as Cilium evaluates them.
+// Host policies select nodes instead of endpoints, so their rules are ignored.
+func parsePolicySelectors(ref policyRef, resource *unstructured.Unstructured) ([]api.EndpointSelector, error) {
+	var rules api.Rules
+	switch ref.Kind {
+	case "CiliumNetworkPolicy", "CiliumClusterwideNetworkPolicy":
+		r, err := parseCiliumPolicyRules(ref, resource)
+		if err != nil {
+			return nil, err
+		}
+		rules = r
+
+	case "NetworkPolicy":
+		var np networkingv1.NetworkPolicy
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, &np); err != nil {
+			return nil, err
+		}
+		// Convert into the slim type used by Cilium
+		data, err := json.Marshal(np.Spec.PodSelector)
+		if err != nil {
+			return nil, err
+		}
+		var podSelector slim_metav1.LabelSelector
+		if err := json.Unmarshal(data, &podSelector); err != nil {
+			return nil, err
+		}
+		es := api.NewESFromK8sLabelSelector(labels.LabelSourceK8sKeyPrefix, &podSelector)
+		es.AddMatch(labels.LabelSourceK8sKeyPrefix+k8sConst.PodNamespaceLabel, np.Namespace)
+		return []api.EndpointSelector{es}, nil
+
+	default:
+		return nil, fmt.Errorf("evaluating the selector of %s is not supported", ref.Kind)
+	}
+
+	ret := make([]api.EndpointSelector, 0, len(rules))
+	for _, r := range rules {
+		if r.NodeSelector.LabelSelector != nil {
+			continue
+		}
+		ret = append(ret, r.EndpointSelector)
+	}
+	return ret, nil
+}
+
+// parsePolicyDefaultDeny returns the endpoint selectors of the rules which enable default deny, per direction.
+// Like Cilium, a rule with allow or deny sections in a direction enables it unless enableDefaultDeny is false.
+// A NetworkPolicy enables it for the directions in its policy types.
+func parsePolicyDefaultDeny(ref policyRef, resource *unstructured.Unstructured) (ingress, egress []api.EndpointSelector, err error) {
+	ingress = make([]api.EndpointSelector, 0)
+	egress = make([]api.EndpointSelector, 0)
+	switch ref.Kind {
+	case "CiliumNetworkPolicy", "CiliumClusterwideNetworkPolicy":
+		rules, err := parseCiliumPolicyRules(ref, resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range rules {
+			if r.NodeSelector.LabelSelector != nil {
+				continue
+			}
+			if (len(r.Ingress) > 0 || len(r.IngressDeny) > 0) && (r.EnableDefaultDeny.Ingress == nil || *r.EnableDefaultDeny.Ingress) {
+				ingress = append(ingress, r.EndpointSelector)
+			}
+			if (len(r.Egress) > 0 || len(r.EgressDeny) > 0) && (r.EnableDefaultDeny.Egress == nil || *r.EnableDefaultDeny.Egress) {
+				egress = append(egress, r.EndpointSelector)
+			}
+		}
+		return ingress, egress, nil
+
+	case "NetworkPolicy":
+		var np networkingv1.NetworkPolicy
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resource.Object, &np); err != nil {
+			return nil, nil, err
+		}
+		selectors, err := parsePolicySelectors(ref, resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Kubernetes defaults the policy types in the same way
+		types := np.Spec.PolicyTypes
+		if len(types) == 0 {
+			types = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+			if len(np.Spec.Egress) > 0 {
+				types = append(types, networkingv1.PolicyTypeEgress)
+			}
+		}
+		if slices.Contains(types, networkingv1.PolicyTypeIngress) {
+			ingress = selectors
+		}
+		if slices.Contains(types, networkingv1.PolicyTypeEgress) {
+			egress = selectors
+		}
+		return ingress, egress, nil
+
+	default:
+		return nil, nil, fmt.Errorf("evaluating the rules of %s is not supported", ref.Kind)
+	}
+}
+
+// selectPolicyIdentities evaluates the endpoint selectors of a policy against the security labels of CiliumIdentities.
+func selectPolicyIdentities(ctx context.Context, dynamicClient *dynamic.DynamicClient, selectors []api.EndpointSelector) ([]uint32, error) {
+	identities, err := getIdentityResourceMap(ctx, dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]uint32, 0)
+	for id, resource := range identities {
+		securityLabels, ok, err := unstructured.NestedStringMap(resource.Object, "security-labels")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		arr := make([]string, 0, len(securityLabels))
+		for k, v := range securityLabels {
+			arr = append(arr, k+"="+v)
+		}
+		lbls := labels.ParseLabelArray(arr...)
+
+		for _, s := range selectors {
+			if s.Matches(lbls) {
+				ret = append(ret, id)
+				break
+			}
+		}
+	}
+	slices.Sort(ret)
+	return ret, nil
+}
+
+// resolvePolicyRef fetches a policy and returns the identities it currently applies to.
+func resolvePolicyRef(ctx context.Context, dynamicClient *dynamic.DynamicClient, ref policyRef) ([]uint32, error) {
+	if _, ok := policyKinds[ref.Kind]; !ok {
+		return nil, errors.New("unsupported policy kind: " + ref.Kind)
+	}
+	resource, err := getPolicyResource(ctx, dynamicClient, ref)
+	if err != nil {
+		return nil, err
+	}
+	selectors, err := parsePolicySelectors(ref, resource)
+	if err != nil {
+		return nil, err
+	}
+	return selectPolicyIdentities(ctx, dynamicClient, selectors)
+}
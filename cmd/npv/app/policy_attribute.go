@@ -0,0 +1,183 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/policy/api"
+	"github.com/cilium/cilium/pkg/u8proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+)
+
+// wildcardSelector is the key of the selector which selects all identities.
+// Rules with this selector are realized as map entries with identity 0.
+// https://github.com/cilium/cilium/blob/v1.17.16/pkg/policy/selectorcache.go
+var wildcardSelector = api.WildcardEndpointSelector.LabelSelector.String()
+
+// produces reports whether the realized rule may have generated the policy map entry.
+func (r *realizedRule) produces(p *proxy.PolicyEntry, selectors map[string][]uint32) bool {
+	if (r.Direction == directionEgress) != p.IsEgress() || r.IsDeny() != p.IsDeny() {
+		return false
+	}
+
+	f := r.Filter
+	if f.Protocol != "ANY" && (p.IsWildcardProtocol() || f.Protocol != u8proto.U8proto(p.GetProtocol()).String()) {
+		return false
+	}
+	switch {
+	case f.PortName != "":
+		// Named ports are resolved per endpoint, so any port may match
+		if p.IsWildcardPort() {
+			return false
+		}
+	case f.Port == 0:
+		if !p.IsWildcardPort() {
+			return false
+		}
+	default:
+		port := p.Key.GetDestPort()
+		if p.IsWildcardPort() || port < f.Port || max(f.Port, f.EndPort) < port {
+			return false
+		}
+	}
+
+	if p.Key.Identity == 0 {
+		return r.Selector == wildcardSelector
+	}
+	return slices.Contains(selectors[r.Selector], p.Key.Identity)
+}
+
+// isDerivedFrom reports whether the rule is derived from the policy.
+func (r *realizedRule) isDerivedFrom(ref policyRef) bool {
+	for _, d := range r.DerivedFrom {
+		e := parseListEntry("", "", d)
+		if makePolicyRef(&e) == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointPolicy is the realized policy of an endpoint with its policy map.
+type endpointPolicy struct {
+	Pod       *corev1.Pod
+	Identity  uint32
+	Enabled   models.EndpointPolicyEnabled
+	Rules     []realizedRule
+	Selectors map[string][]uint32
+	Entries   []proxy.PolicyEntry
+}
+
+// attributeEntry returns the policies which may have generated the policy map entry.
+func (e *endpointPolicy) attributeEntry(p *proxy.PolicyEntry) []policyRef {
+	refs := make(map[policyRef]struct{})
+	for i := range e.Rules {
+		r := &e.Rules[i]
+		if !r.produces(p, e.Selectors) {
+			continue
+		}
+		for _, d := range r.DerivedFrom {
+			entry := parseListEntry("", "", d)
+			refs[makePolicyRef(&entry)] = struct{}{}
+		}
+	}
+	return slices.SortedFunc(maps.Keys(refs), func(x, y policyRef) int { return comparePolicyRef(&x, &y) })
+}
+
+func queryEndpointPolicy(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) (*endpointPolicy, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	response, err := client.GetEndpointResponse(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint info: %w", err)
+	}
+
+	selectors, err := client.GetSelectorIdentities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get selector identities: %w", err)
+	}
+
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity uint32
+	if response.Payload.Status.Identity != nil {
+		identity = uint32(response.Payload.Status.Identity.ID)
+	}
+	return &endpointPolicy{
+		Pod:       pod,
+		Identity:  identity,
+		Enabled:   response.Payload.Status.Policy.Realized.PolicyEnabled,
+		Rules:     expandRealizedRules(response.Payload.Status.Policy.Realized.L4, true, true),
+		Selectors: selectors,
+		Entries:   policies,
+	}, nil
+}
+
+// queryEndpointPolicies queries the realized policies and the policy maps of the endpoints in parallel.
+func queryEndpointPolicies(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pods []*corev1.Pod) []*endpointPolicy {
+	ret := mapNodeReduce(pods,
+		func() []*endpointPolicy {
+			return make([]*endpointPolicy, 0)
+		},
+		func(pod *corev1.Pod) []*endpointPolicy {
+			e, err := queryEndpointPolicy(ctx, stderr, clientset, dynamicClient, pod)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return []*endpointPolicy{e}
+		},
+		func(x, y []*endpointPolicy) []*endpointPolicy {
+			return append(x, y...)
+		},
+	)
+	slices.SortFunc(ret, func(x, y *endpointPolicy) int { return comparePod(x.Pod, y.Pod) })
+	return ret
+}
+
+// getIdentityPods returns the running pods which have the identities.
+func getIdentityPods(ctx context.Context, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, ids []uint32) ([]*corev1.Pod, error) {
+	idEndpoints, err := getIdentityEndpoints(ctx, dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*corev1.Pod, 0)
+	for _, id := range ids {
+		for _, ep := range idEndpoints[id] {
+			nn := types.NamespacedName{Namespace: ep.GetNamespace(), Name: ep.GetName()}
+			pod, err := clientset.CoreV1().Pods(nn.Namespace).Get(ctx, nn.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if pod.Spec.HostNetwork || pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			ret = append(ret, pod)
+		}
+	}
+	slices.SortFunc(ret, comparePod)
+	return ret, nil
+}
+
+func comparePod(x, y *corev1.Pod) int {
+	return cmp.Or(strings.Compare(x.Namespace, y.Namespace), strings.Compare(x.Name, y.Name))
+}
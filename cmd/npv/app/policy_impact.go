@@ -0,0 +1,300 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+)
+
+func init() {
+	policyCmd.AddCommand(policyImpactCmd)
+}
+
+var policyImpactCmd = &cobra.Command{
+	Use:   "impact KIND/NAMESPACE/NAME",
+	Short: "Show connections affected by deleting a policy",
+	Long: `Show connections affected by deleting a policy
+
+The policy maps of the endpoints selected by the policy are examined, and the entries generated only by
+the policy are considered to disappear on deletion. Entries which another policy also generates for the same
+peer, port and protocol are kept. The traffic counted on each entry is then evaluated again on the remaining
+entries, taking into account whether another policy still enables default deny on the endpoint.
+Connections whose verdict changes are shown.
+
+EFFECT is one of the following:
+  dropped       the allowed traffic will be dropped by the remaining policies
+  unrestricted  no policy will enable default deny on the endpoint in the direction, so the traffic will be allowed
+  undenied      the denied traffic will be allowed by the remaining policies`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyImpact(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+	},
+}
+
+const (
+	impactEffectDropped      = "dropped"
+	impactEffectUnrestricted = "unrestricted"
+	impactEffectUndenied     = "undenied"
+)
+
+type policyImpactConnection struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	Policy    string `json:"policy"`
+	Identity  uint32 `json:"identity"`
+	Peer      string `json:"peer"`
+	Port      string `json:"port"`
+	Bytes     uint64 `json:"bytes"`
+	Requests  uint64 `json:"requests"`
+	Effect    string `json:"effect"`
+}
+
+type policyImpactResult struct {
+	Policy         policyRef                `json:"policy"`
+	Endpoints      int                      `json:"endpoints"`
+	RemovedEntries int                      `json:"removed_entries"`
+	Connections    []policyImpactConnection `json:"connections"`
+}
+
+func comparePolicyImpactConnection(x, y *policyImpactConnection) int {
+	return cmp.Or(
+		strings.Compare(x.Namespace, y.Namespace),
+		strings.Compare(x.Name, y.Name),
+		strings.Compare(x.Direction, y.Direction),
+		-strings.Compare(x.Policy, y.Policy),
+		cmp.Compare(x.Identity, y.Identity),
+		strings.Compare(x.Port, y.Port),
+	)
+}
+
+// policyDefaultDeny is a policy with the identities on which it enables default deny, per direction.
+type policyDefaultDeny struct {
+	Ref     policyRef
+	Ingress []uint32
+	Egress  []uint32
+}
+
+// listPolicyDefaultDenies lists CiliumNetworkPolicies, CiliumClusterwideNetworkPolicies, and NetworkPolicies
+// with the identities on which they enable default deny.
+func listPolicyDefaultDenies(ctx context.Context, stderr io.Writer, dynamicClient *dynamic.DynamicClient) ([]policyDefaultDeny, error) {
+	ret := make([]policyDefaultDeny, 0)
+	for _, kind := range []string{"CiliumNetworkPolicy", "CiliumClusterwideNetworkPolicy", "NetworkPolicy"} {
+		li, err := dynamicClient.Resource(policyKinds[kind].resource).Namespace(corev1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range li.Items {
+			ref := policyRef{Kind: kind, Namespace: item.GetNamespace(), Name: item.GetName()}
+			if ref.Namespace == "" {
+				ref.Namespace = "-"
+			}
+			ingress, egress, err := parsePolicyDefaultDeny(ref, &item)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: failed to parse %s: %v\n", ref, err)
+				continue
+			}
+			d := policyDefaultDeny{Ref: ref}
+			if d.Ingress, err = selectPolicyIdentities(ctx, dynamicClient, ingress); err != nil {
+				return nil, err
+			}
+			if d.Egress, err = selectPolicyIdentities(ctx, dynamicClient, egress); err != nil {
+				return nil, err
+			}
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
+
+// isEnforcedWithout reports whether default deny stays enabled on the endpoint in the direction after deleting the policy.
+// Cilium enables default deny in a direction while some policy selecting the endpoint enables it.
+// When the deleted policy does not enable it, the current state is kept, e.g. with policy-enforcement=always.
+func (e *endpointPolicy) isEnforcedWithout(ref policyRef, egress bool, defaultDenies []policyDefaultDeny) bool {
+	enforced, audit := isPolicyEnforced(e.Enabled, egress)
+	if !enforced || audit {
+		return false
+	}
+
+	self := false
+	for _, d := range defaultDenies {
+		ids := d.Ingress
+		if egress {
+			ids = d.Egress
+		}
+		if !slices.Contains(ids, e.Identity) {
+			continue
+		}
+		if d.Ref != ref {
+			return true
+		}
+		self = true
+	}
+	return !self
+}
+
+// isAllowedWithout decides whether the traffic passes the remaining entries.
+// Without default deny, the traffic passes unless a deny entry is selected.
+func isAllowedWithout(remaining []proxy.PolicyEntry, enforced, egress bool, peer uint32, protocol uint8, port uint16) bool {
+	v := proxy.ComputeVerdict(remaining, egress, peer, protocol, port)
+	if enforced {
+		return v.Allowed
+	}
+	return len(v.Decision) == 0 || !v.Decision[0].IsDeny()
+}
+
+// describeIdentity returns a human-readable peer for an identity in the policy map.
+func describeIdentity(ctx context.Context, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, id uint32) (string, error) {
+	if id == 0 {
+		return "ANY", nil
+	}
+	idObj := identity.NumericIdentity(id)
+	switch {
+	case idObj.IsReservedIdentity():
+		return "reserved:" + idObj.String(), nil
+	case idObj.HasLocalScope():
+		return "cidr", nil
+	}
+
+	example, err := getIdentityExample(ctx, dynamicClient, id)
+	if err != nil {
+		return "", err
+	}
+	if example == nil {
+		return "-", nil
+	}
+	peers, err := getIdentityPeers(ctx, clientset, dynamicClient, id)
+	if err != nil {
+		return "", err
+	}
+	return example.GetNamespace() + "/" + peers.Workload, nil
+}
+
+func runPolicyImpact(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	ref, err := parsePolicyRef(name)
+	if err != nil {
+		return err
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	ids, err := resolvePolicyRef(ctx, dynamicClient, ref)
+	if err != nil {
+		return err
+	}
+	pods, err := getIdentityPods(ctx, clientset, dynamicClient, ids)
+	if err != nil {
+		return err
+	}
+	endpoints := queryEndpointPolicies(ctx, stderr, clientset, dynamicClient, pods)
+
+	defaultDenies, err := listPolicyDefaultDenies(ctx, stderr, dynamicClient)
+	if err != nil {
+		return err
+	}
+
+	result := policyImpactResult{
+		Policy:      ref,
+		Endpoints:   len(endpoints),
+		Connections: make([]policyImpactConnection, 0),
+	}
+	for _, e := range endpoints {
+		remaining := make([]proxy.PolicyEntry, 0, len(e.Entries))
+		removed := make([]bool, len(e.Entries))
+		for i := range e.Entries {
+			p := &e.Entries[i]
+			refs := e.attributeEntry(p)
+			if len(refs) == 1 && refs[0] == ref {
+				removed[i] = true
+				result.RemovedEntries++
+				continue
+			}
+			remaining = append(remaining, *p)
+		}
+		enforcedIngress := e.isEnforcedWithout(ref, false, defaultDenies)
+		enforcedEgress := e.isEnforcedWithout(ref, true, defaultDenies)
+
+		// The counters of an entry tell the traffic which hit it, so the traffic is evaluated again without the policy
+		for i := range e.Entries {
+			p := &e.Entries[i]
+			if p.Bytes == 0 && p.Packets == 0 {
+				continue
+			}
+			egress := p.IsEgress()
+			if _, audit := isPolicyEnforced(e.Enabled, egress); audit {
+				continue
+			}
+			enforced := enforcedIngress
+			if egress {
+				enforced = enforcedEgress
+			}
+			allowed := isAllowedWithout(remaining, enforced, egress, p.Key.Identity, p.GetProtocol(), p.Key.GetDestPort())
+
+			var effect string
+			switch {
+			case p.IsDeny() && allowed:
+				effect = impactEffectUndenied
+			case p.IsAllow() && !allowed:
+				effect = impactEffectDropped
+			case p.IsAllow() && removed[i] && !enforced:
+				effect = impactEffectUnrestricted
+			default:
+				continue
+			}
+
+			conn := policyImpactConnection{
+				Namespace: e.Pod.Namespace,
+				Name:      e.Pod.Name,
+				Direction: directionIngress,
+				Policy:    policyAllow,
+				Identity:  p.Key.Identity,
+				Port:      makeReachMatrixRule(p).String(),
+				Bytes:     p.Bytes,
+				Requests:  p.Packets,
+				Effect:    effect,
+			}
+			if egress {
+				conn.Direction = directionEgress
+			}
+			if p.IsDeny() {
+				conn.Policy = policyDeny
+			}
+			conn.Peer, err = describeIdentity(ctx, clientset, dynamicClient, p.Key.Identity)
+			if err != nil {
+				return err
+			}
+			result.Connections = append(result.Connections, conn)
+		}
+	}
+	slices.SortFunc(result.Connections, func(x, y policyImpactConnection) int { return comparePolicyImpactConnection(&x, &y) })
+
+	return writePolicyImpact(stdout, &result)
+}
+
+func writePolicyImpact(w io.Writer, result *policyImpactResult) error {
+	if rootOptions.output == OutputJson {
+		return writeSimpleOrJson(w, result, nil, 0, nil)
+	}
+
+	header := []string{"NAMESPACE", "NAME", "|", "DIRECTION", "POLICY", "|", "IDENTITY", "PEER", "PORT", "|", "BYTES:", "REQUESTS:", "|", "EFFECT"}
+	return writeSimpleOrJson(w, result, header, len(result.Connections), func(index int) []any {
+		p := result.Connections[index]
+		return []any{p.Namespace, p.Name, "|", p.Direction, p.Policy, "|", p.Identity, p.Peer, p.Port, "|", formatWithUnits(p.Bytes), formatWithUnits(p.Requests), "|", p.Effect}
+	})
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	policyCmd.AddCommand(policyShowCmd)
+}
+
+var policyShowCmd = &cobra.Command{
+	Use:   "show KIND/NAMESPACE/NAME",
+	Short: "Show endpoints selected by a policy and the map entries it generates",
+	Long: `Show endpoints selected by a policy and the map entries it generates
+
+KIND is one of cnp, ccnp, netpol, or their full names. Cluster-scoped policies are specified as KIND/NAME.
+The endpoint selector is evaluated against CiliumIdentities, and the policy maps of the selected endpoints
+are examined to find which rules of the policy generated how many entries and how much traffic hit them.`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyShow(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+	},
+}
+
+type policyShowEndpoint struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Node      string `json:"node"`
+	Identity  uint32 `json:"identity"`
+	Workload  string `json:"workload"`
+}
+
+type policyShowRule struct {
+	Direction string `json:"direction"`
+	Policy    string `json:"policy"`
+	Protocol  string `json:"protocol"`
+	Port      string `json:"port"`
+	Selector  string `json:"selector"`
+	Endpoints int    `json:"endpoints"`
+	Entries   int    `json:"entries"`
+	Bytes     uint64 `json:"bytes"`
+	Requests  uint64 `json:"requests"`
+}
+
+type policyShowResult struct {
+	Policy     policyRef            `json:"policy"`
+	Identities []uint32             `json:"identities"`
+	Endpoints  []policyShowEndpoint `json:"endpoints"`
+	Rules      []policyShowRule     `json:"rules"`
+}
+
+func comparePolicyShowRule(x, y *policyShowRule) int {
+	return cmp.Or(
+		strings.Compare(x.Direction, y.Direction),
+		-strings.Compare(x.Policy, y.Policy),
+		strings.Compare(x.Protocol, y.Protocol),
+		strings.Compare(x.Port, y.Port),
+		strings.Compare(x.Selector, y.Selector),
+	)
+}
+
+func makePolicyShowRule(r *realizedRule) policyShowRule {
+	ret := policyShowRule{
+		Direction: r.Direction,
+		Policy:    policyAllow,
+		Protocol:  r.Filter.Protocol,
+		Port:      r.Filter.portString(),
+		Selector:  r.Selector,
+	}
+	if r.IsDeny() {
+		ret.Policy = policyDeny
+	}
+	return ret
+}
+
+func runPolicyShow(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	ref, err := parsePolicyRef(name)
+	if err != nil {
+		return err
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	ids, err := resolvePolicyRef(ctx, dynamicClient, ref)
+	if err != nil {
+		return err
+	}
+	pods, err := getIdentityPods(ctx, clientset, dynamicClient, ids)
+	if err != nil {
+		return err
+	}
+	endpoints := queryEndpointPolicies(ctx, stderr, clientset, dynamicClient, pods)
+
+	result := policyShowResult{
+		Policy:     ref,
+		Identities: ids,
+		Endpoints:  make([]policyShowEndpoint, 0, len(endpoints)),
+	}
+	rules := make(map[policyShowRule]*policyShowRule)
+	for _, e := range endpoints {
+		workload, err := getPodWorkload(ctx, clientset, e.Pod.Namespace, e.Pod.Name)
+		if err != nil {
+			return err
+		}
+		result.Endpoints = append(result.Endpoints, policyShowEndpoint{
+			Namespace: e.Pod.Namespace,
+			Name:      e.Pod.Name,
+			Node:      e.Pod.Spec.NodeName,
+			Identity:  e.Identity,
+			Workload:  workload,
+		})
+
+		addPolicyShowRules(rules, e, ref)
+	}
+	result.Rules = make([]policyShowRule, 0, len(rules))
+	for _, r := range rules {
+		result.Rules = append(result.Rules, *r)
+	}
+	slices.SortFunc(result.Rules, func(x, y policyShowRule) int { return comparePolicyShowRule(&x, &y) })
+
+	return writePolicyShow(stdout, &result)
+}
+
+// addPolicyShowRules accumulates the map entries generated by the rules of the policy on an endpoint.
+// An entry is counted for every rule which may have generated it.
+func addPolicyShowRules(rules map[policyShowRule]*policyShowRule, e *endpointPolicy, ref policyRef) {
+	seen := make(map[policyShowRule]bool)
+	for i := range e.Rules {
+		r := &e.Rules[i]
+		if !r.isDerivedFrom(ref) {
+			continue
+		}
+		key := makePolicyShowRule(r)
+		agg, ok := rules[key]
+		if !ok {
+			copied := key
+			agg = &copied
+			rules[key] = agg
+		}
+		if !seen[key] {
+			seen[key] = true
+			agg.Endpoints++
+		}
+		for j := range e.Entries {
+			p := &e.Entries[j]
+			if !r.produces(p, e.Selectors) {
+				continue
+			}
+			agg.Entries++
+			agg.Bytes += p.Bytes
+			agg.Requests += p.Packets
+		}
+	}
+}
+
+func writePolicyShow(w io.Writer, result *policyShowResult) error {
+	if rootOptions.output == OutputJson {
+		return writeSimpleOrJson(w, result, nil, 0, nil)
+	}
+
+	header := []string{"NAMESPACE", "NAME", "NODE", "IDENTITY", "WORKLOAD"}
+	err := writeSimpleOrJson(w, result, header, len(result.Endpoints), func(index int) []any {
+		p := result.Endpoints[index]
+		return []any{p.Namespace, p.Name, p.Node, p.Identity, p.Workload}
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+
+	header = []string{"DIRECTION", "POLICY", "|", "PROTOCOL", "PORT", "SELECTOR", "|", "ENDPOINTS:", "ENTRIES:", "BYTES:", "REQUESTS:"}
+	return writeSimpleOrJson(w, result, header, len(result.Rules), func(index int) []any {
+		p := result.Rules[index]
+		return []any{p.Direction, p.Policy, "|", p.Protocol, p.Port, p.Selector, "|", p.Endpoints, p.Entries, formatWithUnits(p.Bytes), formatWithUnits(p.Requests)}
+	})
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/gvr"
+)
+
+func init() {
+	policyCmd.AddCommand(policyUnusedCmd)
+}
+
+var policyUnusedCmd = &cobra.Command{
+	Use:   "unused",
+	Short: "List policies and rules which are candidates for cleanup",
+	Long: `List policies and rules which are candidates for cleanup
+
+CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies are reported when they select no endpoints,
+or when the policy map entries generated by them or by their rules have zero bytes on every selected endpoint.
+Policy map counters are reset when an endpoint is recreated, so COUNTERS-SINCE shows the start time of
+the oldest selected endpoint, which is the period the counters cover.`,
+
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyUnused(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+const (
+	unusedReasonNoEndpoints = "no endpoints"
+	unusedReasonNoTraffic   = "no traffic"
+	unusedReasonRule        = "rule has no traffic"
+)
+
+type policyUnusedEntry struct {
+	policyRef
+	Reason        string          `json:"reason"`
+	Endpoints     int             `json:"endpoints"`
+	Rule          *policyShowRule `json:"rule,omitempty"`
+	CountersSince *metav1.Time    `json:"counters_since,omitempty"`
+}
+
+type policyUnusedTarget struct {
+	Ref        policyRef
+	Identities []uint32
+	Endpoints  int
+}
+
+// listPolicyUnusedTargets lists CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies with their selected identities.
+func listPolicyUnusedTargets(ctx context.Context, stderr io.Writer, dynamicClient *dynamic.DynamicClient) ([]policyUnusedTarget, error) {
+	idEndpoints, err := getIdentityEndpoints(ctx, dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+
+	cnps, err := dynamicClient.Resource(gvr.NetworkPolicy).Namespace(corev1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ccnps, err := dynamicClient.Resource(gvr.ClusterwideNetworkPolicy).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]policyUnusedTarget, 0, len(cnps.Items)+len(ccnps.Items))
+	for _, list := range []struct {
+		kind  string
+		items []unstructured.Unstructured
+	}{
+		{"CiliumNetworkPolicy", cnps.Items},
+		{"CiliumClusterwideNetworkPolicy", ccnps.Items},
+	} {
+		for _, item := range list.items {
+			ref := policyRef{Kind: list.kind, Namespace: item.GetNamespace(), Name: item.GetName()}
+			if ref.Namespace == "" {
+				ref.Namespace = "-"
+			}
+			selectors, err := parsePolicySelectors(ref, &item)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: failed to parse %s: %v\n", ref, err)
+				continue
+			}
+			ids, err := selectPolicyIdentities(ctx, dynamicClient, selectors)
+			if err != nil {
+				return nil, err
+			}
+			target := policyUnusedTarget{Ref: ref, Identities: ids}
+			for _, id := range ids {
+				target.Endpoints += len(idEndpoints[id])
+			}
+			ret = append(ret, target)
+		}
+	}
+	slices.SortFunc(ret, func(x, y policyUnusedTarget) int { return comparePolicyRef(&x.Ref, &y.Ref) })
+	return ret, nil
+}
+
+type policyUsage struct {
+	rules map[policyShowRule]*policyShowRule
+	since *metav1.Time
+}
+
+func runPolicyUnused(ctx context.Context, stdout, stderr io.Writer) error {
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	targets, err := listPolicyUnusedTargets(ctx, stderr, dynamicClient)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint32, 0)
+	for _, t := range targets {
+		ids = append(ids, t.Identities...)
+	}
+	slices.Sort(ids)
+	pods, err := getIdentityPods(ctx, clientset, dynamicClient, slices.Compact(ids))
+	if err != nil {
+		return err
+	}
+	endpoints := queryEndpointPolicies(ctx, stderr, clientset, dynamicClient, pods)
+
+	// Attribution comes from DerivedFromRules on each endpoint, so a policy is only accounted on the endpoints it is realized on.
+	usages := make(map[policyRef]*policyUsage)
+	for _, e := range endpoints {
+		refs := make(map[policyRef]bool)
+		for i := range e.Rules {
+			for _, d := range e.Rules[i].DerivedFrom {
+				entry := parseListEntry("", "", d)
+				refs[makePolicyRef(&entry)] = true
+			}
+		}
+		for ref := range refs {
+			u, ok := usages[ref]
+			if !ok {
+				u = &policyUsage{rules: make(map[policyShowRule]*policyShowRule)}
+				usages[ref] = u
+			}
+			addPolicyShowRules(u.rules, e, ref)
+			if start := e.Pod.Status.StartTime; start != nil && (u.since == nil || start.Before(u.since)) {
+				u.since = start
+			}
+		}
+	}
+
+	arr := make([]policyUnusedEntry, 0)
+	for _, t := range targets {
+		if t.Endpoints == 0 {
+			arr = append(arr, policyUnusedEntry{policyRef: t.Ref, Reason: unusedReasonNoEndpoints})
+			continue
+		}
+		u, ok := usages[t.Ref]
+		if !ok {
+			// Selected endpoints exist, but none of them has realized the policy
+			arr = append(arr, policyUnusedEntry{policyRef: t.Ref, Reason: unusedReasonNoEndpoints, Endpoints: t.Endpoints})
+			continue
+		}
+
+		rules := make([]policyShowRule, 0, len(u.rules))
+		var bytes uint64
+		for _, r := range u.rules {
+			rules = append(rules, *r)
+			bytes += r.Bytes
+		}
+		slices.SortFunc(rules, func(x, y policyShowRule) int { return comparePolicyShowRule(&x, &y) })
+
+		if bytes == 0 {
+			arr = append(arr, policyUnusedEntry{policyRef: t.Ref, Reason: unusedReasonNoTraffic, Endpoints: t.Endpoints, CountersSince: u.since})
+			continue
+		}
+		for _, r := range rules {
+			if r.Bytes == 0 {
+				arr = append(arr, policyUnusedEntry{policyRef: t.Ref, Reason: unusedReasonRule, Endpoints: r.Endpoints, Rule: &r, CountersSince: u.since})
+			}
+		}
+	}
+
+	header := []string{"KIND", "NAMESPACE", "NAME", "|", "REASON", "ENDPOINTS:", "|", "DIRECTION", "POLICY", "PROTOCOL", "PORT", "SELECTOR", "|", "COUNTERS-SINCE"}
+	return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
+		p := arr[index]
+		since := "-"
+		if p.CountersSince != nil {
+			since = p.CountersSince.UTC().Format(time.RFC3339)
+		}
+		rule := []any{"-", "-", "-", "-", "-"}
+		if p.Rule != nil {
+			rule = []any{p.Rule.Direction, p.Rule.Policy, p.Rule.Protocol, p.Rule.Port, p.Rule.Selector}
+		}
+		values := []any{p.Kind, p.Namespace, p.Name, "|", p.Reason, p.Endpoints, "|"}
+		values = append(values, rule...)
+		return append(values, "|", since)
+	})
+}
@@ -16,10 +16,17 @@ import (
 )
 
 var reachOptions struct {
-	from     string
-	fromCIDR cidrOptions
-	to       string
-	toCIDR   cidrOptions
+	from       string
+	fromCIDR   cidrOptions
+	fromEntity string
+	to         string
+	toCIDR     cidrOptions
+	toEntity   string
+	toFQDN     string
+	port       uint16
+	protocol   string
+	check      bool
+	suggest    bool
 }
 
 func init() {
@@ -27,33 +34,99 @@ func init() {
 	reachCmd.Flags().StringVar(&reachOptions.fromCIDR.cidrs, "from-cidrs", "", "source CIDRs")
 	reachCmd.Flags().BoolVar(&reachOptions.fromCIDR.privateCIDRs, "from-private-cidrs", false, "use private CIDRs as source (10.0.0.0/8,172.16.0.0/12,192.168.0.0/16)")
 	reachCmd.Flags().BoolVar(&reachOptions.fromCIDR.publicCIDRs, "from-public-cidrs", false, "use public CIDRs as source (0.0.0.0/0,!10.0.0.0/8,!172.16.0.0/12,!192.168.0.0/16)")
-	reachCmd.Flags().StringVar(&reachOptions.to, "to", "", "destination pod")
+	reachCmd.Flags().StringVar(&reachOptions.fromEntity, "from-entity", "", "use Cilium entity as source (world, host, remote-node, kube-apiserver, health, ingress, cluster)")
+	reachCmd.Flags().StringVar(&reachOptions.to, "to", "", "destination pod, or service as svc/NAMESPACE/NAME[:PORT]")
 	reachCmd.Flags().StringVar(&reachOptions.toCIDR.cidrs, "to-cidrs", "", "destination CIDRs")
 	reachCmd.Flags().BoolVar(&reachOptions.toCIDR.privateCIDRs, "to-private-cidrs", false, "use private CIDRs as destination (10.0.0.0/8,172.16.0.0/12,192.168.0.0/16)")
 	reachCmd.Flags().BoolVar(&reachOptions.toCIDR.publicCIDRs, "to-public-cidrs", false, "use public CIDRs as destination (0.0.0.0/0,!10.0.0.0/8,!172.16.0.0/12,!192.168.0.0/16)")
+	reachCmd.Flags().StringVar(&reachOptions.toEntity, "to-entity", "", "use Cilium entity as destination (world, host, remote-node, kube-apiserver, health, ingress, cluster)")
+	reachCmd.Flags().StringVar(&reachOptions.toFQDN, "to-fqdn", "", "use IP addresses of FQDN in the DNS cache as destination")
 	reachCmd.Flags().BoolVar(&inspectOptions.maskCIDRs, "mask-cidrs", false, "mask cluster-external CIDRs and unify them into public, private, and unknown")
+	reachCmd.Flags().Uint16Var(&reachOptions.port, "port", 0, "destination port to compute the verdict for")
+	reachCmd.Flags().StringVar(&reachOptions.protocol, "protocol", "tcp", "protocol to compute the verdict for")
+	reachCmd.Flags().BoolVar(&reachOptions.check, "check", false, "exit with non-zero status when the traffic is denied; requires --port")
+	reachCmd.Flags().BoolVar(&reachOptions.suggest, "suggest", false, "print a CiliumNetworkPolicy which would allow the traffic instead of the verdict; requires --port")
 	reachCmd.RegisterFlagCompletionFunc("from", completeNamespacePods)
 	reachCmd.RegisterFlagCompletionFunc("to", completeNamespacePods)
+	reachCmd.RegisterFlagCompletionFunc("from-entity", completeEntities)
+	reachCmd.RegisterFlagCompletionFunc("to-entity", completeEntities)
 	rootCmd.AddCommand(reachCmd)
 }
 
 var reachCmd = &cobra.Command{
 	Use:   "reach",
 	Short: "List traffic policies between pod pair",
-	Long:  `List traffic policies between pod pair`,
+	Long: `List traffic policies between pod pair
+
+If only one of --from or --to is specified, every peer which the pod may reach or may be reached from is listed.
+If --to is a service, the verdict is computed for every ready backend pod of the service.
+With --suggest, the minimal CiliumNetworkPolicy which would allow the denied traffic is printed.`,
 
 	Args: cobra.ExactArgs(0),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runReach(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+		if err := validateReachOptions(); err != nil {
+			return err
+		}
+		hasPort := cmd.Flags().Changed("port")
+		if strings.HasPrefix(reachOptions.to, servicePrefix) {
+			if hasPort {
+				return errors.New("--port cannot be used with a service; use svc/NAMESPACE/NAME:PORT instead")
+			}
+			err := runReachService(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+			if errors.Is(err, errTrafficDenied) {
+				cmd.SilenceUsage = true
+			}
+			return err
+		}
+		if reachOptions.suggest && !hasPort {
+			return errors.New("--suggest requires --port")
+		}
+		if reachOptions.check && !hasPort {
+			return errors.New("--check requires --port")
+		}
+		if hasPort {
+			var err error
+			switch {
+			case reachOptions.from != "" && reachOptions.to != "":
+				err = runReachVerdict(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+			case reachOptions.from != "" && (reachOptions.toEntity != "" || reachOptions.toFQDN != ""):
+				err = runReachPeerVerdict(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), true)
+			case reachOptions.to != "" && reachOptions.fromEntity != "":
+				err = runReachPeerVerdict(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), false)
+			default:
+				return runReach(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), hasPort)
+			}
+			if errors.Is(err, errTrafficDenied) {
+				cmd.SilenceUsage = true
+			}
+			return err
+		}
+		return runReach(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), hasPort)
 	},
 }
 
+func validateReachOptions() error {
+	if reachOptions.fromEntity != "" && (reachOptions.from != "" || reachOptions.fromCIDR.isSet()) {
+		return errors.New("--from-entity cannot be used with --from or --from-cidrs")
+	}
+	if (reachOptions.toEntity != "" || reachOptions.toFQDN != "") && (reachOptions.to != "" || reachOptions.toCIDR.isSet()) {
+		return errors.New("--to-entity and --to-fqdn cannot be used with --to or --to-cidrs")
+	}
+	if reachOptions.toEntity != "" && reachOptions.toFQDN != "" {
+		return errors.New("--to-entity and --to-fqdn cannot be used together")
+	}
+	if reachOptions.suggest && (reachOptions.from == "" || reachOptions.to == "" || strings.HasPrefix(reachOptions.to, servicePrefix)) {
+		return errors.New("--suggest requires both --from and --to pods")
+	}
+	return nil
+}
+
 type reachEntry struct {
 	inspectEntry
 	Role string `json:"role"`
 }
 
-func runReach(ctx context.Context, stdout, stderr io.Writer) error {
+func runReach(ctx context.Context, stdout, stderr io.Writer, hasPort bool) error {
 	var from, to *types.NamespacedName
 	if reachOptions.from != "" {
 		f, err := parseNamespacedName(reachOptions.from)
@@ -76,12 +149,34 @@ func runReach(ctx context.Context, stdout, stderr io.Writer) error {
 		// To obtain a meaningful result, one of --from or --to must be specified.
 		return errors.New("one of --from or --to must be specified")
 	}
+	if reachOptions.check {
+		return errors.New("--check requires both ends of the traffic")
+	}
 
 	clientset, dynamicClient, err := createK8sClients()
 	if err != nil {
 		return err
 	}
 
+	toPeers, err := resolveReachPeerIdentities(ctx, stderr, clientset, dynamicClient, from, reachOptions.toEntity, reachOptions.toFQDN)
+	if err != nil {
+		return err
+	}
+	fromPeers, err := resolveReachPeerIdentities(ctx, stderr, clientset, dynamicClient, to, reachOptions.fromEntity, "")
+	if err != nil {
+		return err
+	}
+
+	// List every peer when the other side is not specified.
+	switch {
+	case from != nil && to == nil && !reachOptions.toCIDR.isSet() && toPeers == nil:
+		return runReachPeers(ctx, stdout, stderr, clientset, dynamicClient, *from, true, hasPort)
+	case to != nil && from == nil && !reachOptions.fromCIDR.isSet() && fromPeers == nil:
+		return runReachPeers(ctx, stdout, stderr, clientset, dynamicClient, *to, false, hasPort)
+	case hasPort:
+		return errors.New("--port cannot be used with CIDR options")
+	}
+
 	arr := make([]reachEntry, 0)
 
 	// process from-egress
@@ -95,13 +190,13 @@ func runReach(ctx context.Context, stdout, stderr io.Writer) error {
 				return err
 			}
 			filter = proxy.MakeIdentityFilter(false, true, identity)
+		case toPeers != nil:
+			filter = makeReachPeerFilter(false, true, toPeers)
 		case reachOptions.toCIDR.isSet():
 			filter, err = parseCIDROptions(false, true, "to", &reachOptions.toCIDR)
 			if err != nil {
 				return err
 			}
-		default:
-			return errors.New("one of --to or --to-cidrs must be specified")
 		}
 
 		pod, err := clientset.CoreV1().Pods(from.Namespace).Get(ctx, from.Name, metav1.GetOptions{})
@@ -109,7 +204,7 @@ func runReach(ctx context.Context, stdout, stderr io.Writer) error {
 			return err
 		}
 
-		rules, err := runInspectOnPod(ctx, stderr, clientset, dynamicClient, filter, pod)
+		rules, err := runInspectOnPod(ctx, stderr, clientset, dynamicClient, filter, pod, nil)
 		if err != nil {
 			return err
 		}
@@ -132,13 +227,13 @@ func runReach(ctx context.Context, stdout, stderr io.Writer) error {
 				return err
 			}
 			filter = proxy.MakeIdentityFilter(true, false, identity)
+		case fromPeers != nil:
+			filter = makeReachPeerFilter(true, false, fromPeers)
 		case reachOptions.fromCIDR.isSet():
 			filter, err = parseCIDROptions(true, false, "from", &reachOptions.fromCIDR)
 			if err != nil {
 				return err
 			}
-		default:
-			return errors.New("one of --from or --from-cidrs must be specified")
 		}
 
 		pod, err := clientset.CoreV1().Pods(to.Namespace).Get(ctx, to.Name, metav1.GetOptions{})
@@ -146,7 +241,7 @@ func runReach(ctx context.Context, stdout, stderr io.Writer) error {
 			return err
 		}
 
-		rules, err := runInspectOnPod(ctx, stderr, clientset, dynamicClient, filter, pod)
+		rules, err := runInspectOnPod(ctx, stderr, clientset, dynamicClient, filter, pod, nil)
 		if err != nil {
 			return err
 		}
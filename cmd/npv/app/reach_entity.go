@@ -0,0 +1,210 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+)
+
+// reachEntities maps Cilium entities to reserved identities.
+// "world" includes the per-family identities used on dual-stack clusters.
+// "cluster" covers the reserved identities inside the cluster; use --from/--to for pods.
+// https://docs.cilium.io/en/stable/security/policy/language/#entities-based
+var reachEntities = map[string][]identity.NumericIdentity{
+	"world": {
+		identity.ReservedIdentityWorld,
+		identity.ReservedIdentityWorldIPv4,
+		identity.ReservedIdentityWorldIPv6,
+	},
+	"host":           {identity.ReservedIdentityHost},
+	"remote-node":    {identity.ReservedIdentityRemoteNode},
+	"kube-apiserver": {identity.ReservedIdentityKubeAPIServer},
+	"health":         {identity.ReservedIdentityHealth},
+	"ingress":        {identity.ReservedIdentityIngress},
+	"cluster": {
+		identity.ReservedIdentityHost,
+		identity.ReservedIdentityRemoteNode,
+		identity.ReservedIdentityKubeAPIServer,
+		identity.ReservedIdentityHealth,
+		identity.ReservedIdentityIngress,
+		identity.ReservedIdentityInit,
+	},
+}
+
+func completeEntities(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return slices.Sorted(maps.Keys(reachEntities)), cobra.ShellCompDirectiveNoFileComp
+}
+
+// reachPeerIdentity is a peer which is not a pod, such as an entity or an FQDN.
+type reachPeerIdentity struct {
+	Peer     string `json:"peer"`
+	Identity uint32 `json:"identity"`
+}
+
+// resolveReachPeerIdentities resolves an entity or an FQDN into identities.
+// FQDNs are looked up in the DNS proxy cache on the node of the pod, because the cache is per node.
+// It returns nil when neither is specified.
+func resolveReachPeerIdentities(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *types.NamespacedName, entity, fqdn string) ([]reachPeerIdentity, error) {
+	switch {
+	case entity != "":
+		ids, ok := reachEntities[entity]
+		if !ok {
+			return nil, fmt.Errorf("unknown entity %s: should be one of %s", entity, strings.Join(slices.Sorted(maps.Keys(reachEntities)), ", "))
+		}
+		ret := make([]reachPeerIdentity, len(ids))
+		for i, id := range ids {
+			ret[i] = reachPeerIdentity{
+				Peer:     "reserved:" + id.String(),
+				Identity: uint32(id),
+			}
+		}
+		return ret, nil
+
+	case fqdn != "":
+		if pod == nil {
+			return nil, errors.New("--to-fqdn requires --from")
+		}
+		client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+		}
+		result, err := client.GetFQDNIdentities(ctx, fqdn)
+		if err != nil {
+			return nil, err
+		}
+		if len(result) == 0 {
+			return nil, fmt.Errorf("%s is not found in the DNS cache; the name needs to be resolved by a pod on the same node first", fqdn)
+		}
+		ret := make([]reachPeerIdentity, len(result))
+		for i, r := range result {
+			ret[i] = reachPeerIdentity{
+				Peer:     fmt.Sprintf("fqdn:%s(%s)", r.FQDN, r.IP),
+				Identity: r.Identity,
+			}
+		}
+		return ret, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+func makeReachPeerFilter(ingress, egress bool, peers []reachPeerIdentity) proxy.PolicyFilter {
+	filters := make([]proxy.PolicyFilter, 0, len(peers))
+	for _, p := range peers {
+		if identity.NumericIdentity(p.Identity).HasLocalScope() {
+			// MakeIdentityFilter only accepts global identities
+			filters = append(filters, proxy.MakeAnyFilter(
+				makeExactIdentityFilter(ingress, egress, p.Identity),
+				proxy.MakeIdentityFilter(ingress, egress, 0),
+			))
+			continue
+		}
+		filters = append(filters, proxy.MakeIdentityFilter(ingress, egress, p.Identity))
+	}
+	return proxy.MakeAnyFilter(filters...)
+}
+
+func makeExactIdentityFilter(ingress, egress bool, id uint32) proxy.PolicyFilter {
+	return func(ctx context.Context, client *proxy.Client, p *proxy.PolicyEntry) (bool, error) {
+		if (p.IsIngress() && !ingress) || (p.IsEgress() && !egress) {
+			return false, nil
+		}
+		return p.Key.Identity == id, nil
+	}
+}
+
+type reachPeerVerdict struct {
+	reachPeerIdentity
+	Side reachVerdictSide `json:"side"`
+}
+
+type reachPeerVerdicts struct {
+	Protocol string             `json:"protocol"`
+	Port     uint16             `json:"port"`
+	Peers    []reachPeerVerdict `json:"peers"`
+	Verdict  string             `json:"verdict"`
+}
+
+// runReachPeerVerdict computes the verdict between a pod and an entity or an FQDN.
+// Only the pod side is examined because the peer is not a Cilium endpoint.
+func runReachPeerVerdict(ctx context.Context, stdout, stderr io.Writer, egress bool) error {
+	name := reachOptions.to
+	if egress {
+		name = reachOptions.from
+	}
+	target, err := parseNamespacedName(name)
+	if err != nil {
+		return errors.New("--from and --to should be specified as NAMESPACE/POD")
+	}
+	protocol, err := parseReachProtocol()
+	if err != nil {
+		return err
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	var peers []reachPeerIdentity
+	if egress {
+		peers, err = resolveReachPeerIdentities(ctx, stderr, clientset, dynamicClient, &target, reachOptions.toEntity, reachOptions.toFQDN)
+	} else {
+		peers, err = resolveReachPeerIdentities(ctx, stderr, clientset, dynamicClient, &target, reachOptions.fromEntity, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	pod, err := clientset.CoreV1().Pods(target.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	result := reachPeerVerdicts{
+		Peers: make([]reachPeerVerdict, 0, len(peers)),
+	}
+	sides := make([]reachVerdictSide, 0, len(peers))
+	for _, p := range peers {
+		side, err := computeReachVerdictOnPod(ctx, stderr, clientset, dynamicClient, pod, egress, p.Identity, protocol, reachOptions.port)
+		if err != nil {
+			return err
+		}
+		result.Peers = append(result.Peers, reachPeerVerdict{reachPeerIdentity: p, Side: *side})
+		sides = append(sides, *side)
+	}
+	v := makeReachVerdict(protocol, reachOptions.port, sides)
+	result.Protocol = v.Protocol
+	result.Port = v.Port
+	result.Verdict = v.Verdict
+
+	header := []string{"ROLE", "DIRECTION", "|", "PEER", "IDENTITY", "|", "VERDICT", "REASON"}
+	err = writeSimpleOrJson(stdout, result, header, len(result.Peers)+1, func(index int) []any {
+		if index == len(result.Peers) {
+			return []any{trafficRoleOverall, "-", "|", "-", "-", "|", result.Verdict, ""}
+		}
+		p := result.Peers[index]
+		return []any{p.Side.Role, p.Side.Direction, "|", p.Peer, p.Identity, "|", p.Side.Verdict, p.Side.Reason}
+	})
+	if err != nil {
+		return err
+	}
+	if reachOptions.check && !v.isAllowed() {
+		return errTrafficDenied
+	}
+	return nil
+}
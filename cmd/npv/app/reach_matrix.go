@@ -0,0 +1,568 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/u8proto"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+const (
+	matrixByNamespace = "namespace"
+	matrixByWorkload  = "workload"
+)
+
+var reachMatrixOptions struct {
+	by string
+}
+
+func init() {
+	reachMatrixCmd.Flags().StringVar(&reachMatrixOptions.by, "by", matrixByNamespace, "unit of the matrix (namespace, workload)")
+	addPodSelectorOption(reachMatrixCmd)
+	reachCmd.AddCommand(reachMatrixCmd)
+}
+
+var reachMatrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Show reachability matrix between namespaces or workloads",
+	Long: `Show reachability matrix between namespaces or workloads
+
+A cell is allowed when the sender allows the egress traffic and the receiver allows the ingress traffic.
+Ports denied within an allowed range are excluded, so the range is shown as the remaining port ranges.
+The output format can be simple, json, csv, or html (heat map).`,
+
+	Args: cobra.ExactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReachMatrix(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+type reachMatrixRule struct {
+	WildcardProtocol bool
+	WildcardPort     bool
+	Protocol         uint8
+	Port             uint16
+	PortPrefixLen    uint8
+}
+
+func makeReachMatrixRule(p *proxy.PolicyEntry) reachMatrixRule {
+	return reachMatrixRule{
+		WildcardProtocol: p.IsWildcardProtocol(),
+		WildcardPort:     p.IsWildcardPort(),
+		Protocol:         p.GetProtocol(),
+		Port:             p.Key.GetDestPort(),
+		PortPrefixLen:    p.Key.GetPortPrefixLen(),
+	}
+}
+
+// lastPort returns the last port of the range covered by the rule.
+func (r reachMatrixRule) lastPort() uint16 {
+	return r.Port | uint16(uint32(1)<<(16-uint32(r.PortPrefixLen))-1)
+}
+
+func compareReachMatrixRule(x, y *reachMatrixRule) int {
+	switch {
+	case x.WildcardProtocol != y.WildcardProtocol:
+		if x.WildcardProtocol {
+			return -1
+		}
+		return 1
+	case x.Protocol != y.Protocol:
+		return int(x.Protocol) - int(y.Protocol)
+	case x.WildcardPort != y.WildcardPort:
+		if x.WildcardPort {
+			return -1
+		}
+		return 1
+	case x.Port != y.Port:
+		return int(x.Port) - int(y.Port)
+	default:
+		// List wider ranges first
+		return int(x.PortPrefixLen) - int(y.PortPrefixLen)
+	}
+}
+
+func (r reachMatrixRule) String() string {
+	switch {
+	case r.WildcardProtocol:
+		return "ANY"
+	case r.WildcardPort:
+		return u8proto.U8proto(r.Protocol).String() + "/ANY"
+	case r.PortPrefixLen < 16:
+		return fmt.Sprintf("%s/%d-%d", u8proto.U8proto(r.Protocol), r.Port, r.lastPort())
+	default:
+		return fmt.Sprintf("%s/%d", u8proto.U8proto(r.Protocol), r.Port)
+	}
+}
+
+// intersectReachMatrixRule returns the traffic allowed by both rules.
+func intersectReachMatrixRule(x, y reachMatrixRule) (reachMatrixRule, bool) {
+	switch {
+	case x.WildcardProtocol:
+		return y, true
+	case y.WildcardProtocol:
+		return x, true
+	case x.Protocol != y.Protocol:
+		return reachMatrixRule{}, false
+	case x.WildcardPort:
+		return y, true
+	case y.WildcardPort:
+		return x, true
+	case x.Port > y.lastPort() || y.Port > x.lastPort():
+		return reachMatrixRule{}, false
+	case x.PortPrefixLen >= y.PortPrefixLen:
+		// Port ranges are aligned to their sizes, so overlapping ranges are nested
+		return x, true
+	default:
+		return y, true
+	}
+}
+
+func normalizeReachMatrixRules(rules []reachMatrixRule) []reachMatrixRule {
+	slices.SortFunc(rules, func(x, y reachMatrixRule) int { return compareReachMatrixRule(&x, &y) })
+	return slices.Compact(rules)
+}
+
+// reachMatrixProtocols are the protocols listed separately when traffic of any protocol is only partially allowed.
+var reachMatrixProtocols = []uint8{
+	uint8(u8proto.ICMP),
+	uint8(u8proto.TCP),
+	uint8(u8proto.UDP),
+	uint8(u8proto.ICMPv6),
+	uint8(u8proto.SCTP),
+}
+
+// splitReachMatrixRule returns the part of the rule for which allowed returns true, and whether it is the whole rule.
+// The rule is split at the boundaries of the entries, so that the verdict does not change within each piece.
+// When a rule of any protocol is partially allowed, the protocols in reachMatrixProtocols and the entries are listed.
+func splitReachMatrixRule(r reachMatrixRule, entries []proxy.PolicyEntry, allowed func(protocol uint8, port uint16) bool) ([]reachMatrixRule, bool) {
+	if r.WildcardProtocol {
+		protocols := slices.Clone(reachMatrixProtocols)
+		for _, p := range entries {
+			if !p.IsWildcardProtocol() {
+				protocols = append(protocols, p.GetProtocol())
+			}
+		}
+		if len(protocols) == len(reachMatrixProtocols) {
+			// No entry distinguishes protocols
+			if allowed(0, 0) {
+				return []reachMatrixRule{r}, true
+			}
+			return nil, false
+		}
+		slices.Sort(protocols)
+
+		ret := make([]reachMatrixRule, 0)
+		whole := true
+		for _, protocol := range slices.Compact(protocols) {
+			pieces, ok := splitReachMatrixRule(reachMatrixRule{WildcardPort: true, Protocol: protocol}, entries, allowed)
+			ret = append(ret, pieces...)
+			whole = whole && ok
+		}
+		if whole {
+			return []reachMatrixRule{r}, true
+		}
+		return ret, false
+	}
+
+	split := false
+	for _, p := range entries {
+		if p.IsWildcardProtocol() || p.GetProtocol() != r.Protocol || p.Key.GetPortPrefixLen() <= r.PortPrefixLen {
+			continue
+		}
+		if port := p.Key.GetDestPort(); r.Port <= port && port <= r.lastPort() {
+			split = true
+			break
+		}
+	}
+	if !split {
+		if allowed(r.Protocol, r.Port) {
+			return []reachMatrixRule{r}, true
+		}
+		return nil, false
+	}
+
+	half := reachMatrixRule{Protocol: r.Protocol, Port: r.Port, PortPrefixLen: r.PortPrefixLen + 1}
+	lower, lowerOK := splitReachMatrixRule(half, entries, allowed)
+	half.Port |= uint16(1) << (15 - r.PortPrefixLen)
+	upper, upperOK := splitReachMatrixRule(half, entries, allowed)
+	if lowerOK && upperOK {
+		return []reachMatrixRule{r}, true
+	}
+	return append(lower, upper...), false
+}
+
+// findAllowedRules returns the traffic which the sender may send to the receiver and the receiver may accept.
+// The candidates are the intersections of the allow entries applicable to the pair. Each candidate is split at the
+// boundaries of the entries for the pair and each piece is evaluated with the verdict engine on both sides,
+// so that deny entries and more specific entries within a candidate are honored.
+func findAllowedRules(sender, receiver uint32, senderPolicies, receiverPolicies []proxy.PolicyEntry) []reachMatrixRule {
+	applicable := func(policies []proxy.PolicyEntry, egress bool, peer uint32) []proxy.PolicyEntry {
+		return slices.DeleteFunc(slices.Clone(policies), func(p proxy.PolicyEntry) bool {
+			return p.IsEgress() != egress || (p.Key.Identity != 0 && p.Key.Identity != peer)
+		})
+	}
+	candidates := func(entries []proxy.PolicyEntry) []reachMatrixRule {
+		ret := make([]reachMatrixRule, 0)
+		for _, p := range entries {
+			if p.IsAllow() {
+				ret = append(ret, makeReachMatrixRule(&p))
+			}
+		}
+		return normalizeReachMatrixRules(ret)
+	}
+	allowed := func(protocol uint8, port uint16) bool {
+		return proxy.ComputeVerdict(senderPolicies, true, receiver, protocol, port).Allowed &&
+			proxy.ComputeVerdict(receiverPolicies, false, sender, protocol, port).Allowed
+	}
+
+	senderEntries := applicable(senderPolicies, true, receiver)
+	receiverEntries := applicable(receiverPolicies, false, sender)
+	entries := append(slices.Clone(senderEntries), receiverEntries...)
+
+	ret := make([]reachMatrixRule, 0)
+	for _, e := range candidates(senderEntries) {
+		for _, i := range candidates(receiverEntries) {
+			r, ok := intersectReachMatrixRule(e, i)
+			if !ok {
+				continue
+			}
+			pieces, _ := splitReachMatrixRule(r, entries, allowed)
+			ret = append(ret, pieces...)
+		}
+	}
+	return normalizeReachMatrixRules(ret)
+}
+
+// reachMatrixKey is a pair of sender and receiver identities.
+type reachMatrixKey struct {
+	from uint32
+	to   uint32
+}
+
+type reachMatrixRules struct {
+	rules []reachMatrixRule
+	bytes uint64
+}
+
+// reachMatrixSides holds the policy maps of the selected identities and the traffic observed between identities.
+// Endpoints with the same identity share the same policy, so a policy map of any of them represents the identity.
+type reachMatrixSides struct {
+	policies map[uint32][]proxy.PolicyEntry
+	egress   map[reachMatrixKey]uint64
+	ingress  map[reachMatrixKey]uint64
+}
+
+func newReachMatrixSides() *reachMatrixSides {
+	return &reachMatrixSides{
+		policies: make(map[uint32][]proxy.PolicyEntry),
+		egress:   make(map[reachMatrixKey]uint64),
+		ingress:  make(map[reachMatrixKey]uint64),
+	}
+}
+
+func addReachMatrixRules[K comparable](m map[K]*reachMatrixRules, key K, rules []reachMatrixRule, bytes uint64) {
+	v, ok := m[key]
+	if !ok {
+		v = &reachMatrixRules{}
+		m[key] = v
+	}
+	v.rules = normalizeReachMatrixRules(append(v.rules, rules...))
+	v.bytes += bytes
+}
+
+func mergeReachMatrixSides(x, y *reachMatrixSides) *reachMatrixSides {
+	for k, v := range y.policies {
+		if _, ok := x.policies[k]; !ok {
+			x.policies[k] = v
+		}
+	}
+	for k, v := range y.egress {
+		x.egress[k] += v
+	}
+	for k, v := range y.ingress {
+		x.ingress[k] += v
+	}
+	return x
+}
+
+type reachMatrixEntry struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Allowed bool     `json:"allowed"`
+	Ports   []string `json:"ports"`
+	Bytes   uint64   `json:"bytes"`
+}
+
+func runReachMatrixOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod, podIdentity uint32) (*reachMatrixSides, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := newReachMatrixSides()
+	ret.policies[podIdentity] = policies
+	for _, p := range policies {
+		// Traffic matched by wildcard entries cannot be attributed to a peer.
+		if p.IsDeny() || p.Key.Identity == 0 {
+			continue
+		}
+		if p.IsEgress() {
+			ret.egress[reachMatrixKey{from: podIdentity, to: p.Key.Identity}] += p.Bytes
+		} else {
+			ret.ingress[reachMatrixKey{from: p.Key.Identity, to: podIdentity}] += p.Bytes
+		}
+	}
+	return ret, nil
+}
+
+func runReachMatrix(ctx context.Context, stdout, stderr io.Writer) error {
+	switch reachMatrixOptions.by {
+	case matrixByNamespace, matrixByWorkload:
+	default:
+		return fmt.Errorf("--by should be one of: %s, %s", matrixByNamespace, matrixByWorkload)
+	}
+	switch rootOptions.output {
+	case OutputSimple, OutputJson, OutputCSV, OutputHTML:
+	default:
+		return fmt.Errorf("unknown format: %s", rootOptions.output)
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, "")
+	if err != nil {
+		return err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return err
+	}
+
+	idEndpoints, err := getIdentityEndpoints(ctx, dynamicClient)
+	if err != nil {
+		return err
+	}
+	podIdentities := make(map[types.NamespacedName]uint32)
+	for id, eps := range idEndpoints {
+		for _, ep := range eps {
+			podIdentities[types.NamespacedName{Namespace: ep.GetNamespace(), Name: ep.GetName()}] = id
+		}
+	}
+
+	// key: identity, value: groups of the selected pods having the identity
+	identityGroups := make(map[uint32][]string)
+	groups := make([]string, 0)
+	for _, pod := range pods {
+		id, ok := podIdentities[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]
+		if !ok {
+			continue
+		}
+		group := pod.Namespace
+		if reachMatrixOptions.by == matrixByWorkload {
+			workload, err := getPodWorkload(ctx, clientset, pod.Namespace, pod.Name)
+			if err != nil {
+				return err
+			}
+			group = pod.Namespace + "/" + workload
+		}
+		if !slices.Contains(identityGroups[id], group) {
+			identityGroups[id] = append(identityGroups[id], group)
+		}
+		groups = append(groups, group)
+	}
+	slices.Sort(groups)
+	groups = slices.Compact(groups)
+
+	sides := mapNodeReduce(pods,
+		newReachMatrixSides,
+		func(pod *corev1.Pod) *reachMatrixSides {
+			id, ok := podIdentities[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]
+			if !ok {
+				return newReachMatrixSides()
+			}
+			result, err := runReachMatrixOnPod(ctx, stderr, clientset, dynamicClient, pod, id)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return newReachMatrixSides()
+			}
+			return result
+		},
+		mergeReachMatrixSides,
+	)
+
+	// key: "from" group and "to" group
+	cells := make(map[[2]string]*reachMatrixRules)
+	for from, fromGroups := range identityGroups {
+		for to, toGroups := range identityGroups {
+			key := reachMatrixKey{from: from, to: to}
+			bytes := max(sides.egress[key], sides.ingress[key])
+
+			rules := make([]reachMatrixRule, 0)
+			if senderPolicies, ok := sides.policies[from]; ok {
+				if receiverPolicies, ok := sides.policies[to]; ok {
+					rules = findAllowedRules(from, to, senderPolicies, receiverPolicies)
+				}
+			}
+			if len(rules) == 0 && bytes == 0 {
+				continue
+			}
+			for _, fg := range fromGroups {
+				for _, tg := range toGroups {
+					addReachMatrixRules(cells, [2]string{fg, tg}, rules, bytes)
+				}
+			}
+		}
+	}
+
+	arr := make([]reachMatrixEntry, 0, len(groups)*len(groups))
+	for _, from := range groups {
+		for _, to := range groups {
+			entry := reachMatrixEntry{
+				From:  from,
+				To:    to,
+				Ports: make([]string, 0),
+			}
+			if v, ok := cells[[2]string{from, to}]; ok {
+				entry.Allowed = len(v.rules) > 0
+				for _, r := range v.rules {
+					entry.Ports = append(entry.Ports, r.String())
+				}
+				entry.Bytes = v.bytes
+			}
+			arr = append(arr, entry)
+		}
+	}
+
+	switch rootOptions.output {
+	case OutputCSV:
+		return writeReachMatrixCSV(stdout, arr)
+	case OutputHTML:
+		return writeReachMatrixHTML(stdout, groups, arr)
+	case OutputJson:
+		return writeSimpleOrJson(stdout, arr, nil, 0, nil)
+	default:
+		return writeReachMatrixTable(stdout, groups, arr)
+	}
+}
+
+func formatReachMatrixCell(e *reachMatrixEntry) string {
+	if !e.Allowed {
+		return "-"
+	}
+	return strings.Join(e.Ports, ",")
+}
+
+// writeReachMatrixTable writes the matrix with senders as rows and receivers as columns.
+func writeReachMatrixTable(w io.Writer, groups []string, arr []reachMatrixEntry) error {
+	header := append([]string{"FROM\\TO", "|"}, groups...)
+	return writeSimpleOrJson(w, arr, header, len(groups), func(index int) []any {
+		values := []any{groups[index], "|"}
+		for j := range groups {
+			values = append(values, formatReachMatrixCell(&arr[index*len(groups)+j]))
+		}
+		return values
+	})
+}
+
+func writeReachMatrixCSV(w io.Writer, arr []reachMatrixEntry) error {
+	cw := csv.NewWriter(w)
+	if !rootOptions.noHeaders {
+		if err := cw.Write([]string{"from", "to", "allowed", "ports", "bytes"}); err != nil {
+			return err
+		}
+	}
+	for _, e := range arr {
+		record := []string{e.From, e.To, fmt.Sprint(e.Allowed), strings.Join(e.Ports, " "), fmt.Sprint(e.Bytes)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// reachMatrixColor returns the background color of a heat map cell.
+// Allowed cells get darker as more traffic is observed, on a logarithmic scale.
+func reachMatrixColor(e *reachMatrixEntry, maxBytes uint64) string {
+	if !e.Allowed {
+		return "#eeeeee"
+	}
+	if e.Bytes == 0 || maxBytes == 0 {
+		return "#e5f5e0"
+	}
+	ratio := math.Log1p(float64(e.Bytes)) / math.Log1p(float64(maxBytes))
+	// interpolate from light orange (#fdd49e) to dark red (#b30000)
+	r := int(0xfd + (0xb3-0xfd)*ratio)
+	g := int(0xd4 + (0x00-0xd4)*ratio)
+	b := int(0x9e + (0x00-0x9e)*ratio)
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// writeReachMatrixHTML writes the matrix as a standalone HTML heat map.
+// html/template is restricted by the linter, so every value is escaped by hand.
+func writeReachMatrixHTML(w io.Writer, groups []string, arr []reachMatrixEntry) error {
+	var maxBytes uint64
+	for _, e := range arr {
+		maxBytes = max(maxBytes, e.Bytes)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Reachability matrix</title>
+<style>
+table { border-collapse: collapse; font-family: sans-serif; font-size: 12px; }
+th, td { border: 1px solid #cccccc; padding: 4px; }
+th.to { writing-mode: vertical-rl; }
+td { text-align: center; min-width: 24px; }
+</style>
+</head>
+<body>
+<table>
+<tr><th>from \ to</th>`)
+	for _, g := range groups {
+		fmt.Fprintf(&sb, `<th class="to">%s</th>`, html.EscapeString(g))
+	}
+	sb.WriteString("</tr>\n")
+	for i, from := range groups {
+		fmt.Fprintf(&sb, "<tr><th>%s</th>", html.EscapeString(from))
+		for j := range groups {
+			e := &arr[i*len(groups)+j]
+			title := fmt.Sprintf("%s -> %s\nports: %s\nbytes: %s", e.From, e.To, formatReachMatrixCell(e), formatWithUnits(e.Bytes))
+			text := ""
+			if e.Allowed {
+				text = html.EscapeString(formatWithUnits(e.Bytes))
+			}
+			fmt.Fprintf(&sb, `<td style="background-color: %s" title="%s">%s</td>`, reachMatrixColor(e, maxBytes), html.EscapeString(title), text)
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</table>\n</body>\n</html>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
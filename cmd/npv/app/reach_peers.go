@@ -0,0 +1,251 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/cilium/cilium/pkg/maps/policymap"
+	"github.com/cilium/cilium/pkg/u8proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+)
+
+// reachPeerEntry is a peer which the subject pod may send traffic to, or receive traffic from.
+type reachPeerEntry struct {
+	inspectEntry
+	Role        string `json:"role"`
+	PeerVerdict string `json:"peer_verdict"`
+	PeerReason  string `json:"peer_reason"`
+	Verdict     string `json:"verdict"`
+}
+
+type reachPeerVerdictKey struct {
+	identity uint32
+	protocol uint8
+	port     uint16
+}
+
+// collectReachablePolicies returns the peers allowed in the direction, one entry per peer identity and decision.
+// Every identity found in the policy map is evaluated with the verdict engine against the whole map,
+// so a wildcard allow does not hide the deny entries of a specific identity. Identity 0 stands for
+// the identities which have no entries of their own. The returned entries carry the peer identity in their keys,
+// and keep the counters only when the decision was made by an entry of the peer identity itself.
+// When hasPort is false, each identity is evaluated at the protocol and port of the allow entries applicable to it.
+func collectReachablePolicies(policies []proxy.PolicyEntry, egress bool, hasPort bool, protocol uint8, port uint16) []proxy.PolicyEntry {
+	peers := []uint32{0}
+	for _, p := range policies {
+		if p.IsEgress() == egress && p.Key.Identity != 0 && !slices.Contains(peers, p.Key.Identity) {
+			peers = append(peers, p.Key.Identity)
+		}
+	}
+
+	type seenKey struct {
+		peer     uint32
+		decision policymap.PolicyKey
+	}
+	seen := make(map[seenKey]bool)
+	ret := make([]proxy.PolicyEntry, 0)
+	for _, peer := range peers {
+		for _, p := range policies {
+			if p.IsEgress() != egress || p.IsDeny() || (p.Key.Identity != 0 && p.Key.Identity != peer) {
+				continue
+			}
+			proto, prt := p.GetProtocol(), p.Key.GetDestPort()
+			if hasPort {
+				proto, prt = protocol, port
+			}
+			v := proxy.ComputeVerdict(policies, egress, peer, proto, prt)
+			if !v.Allowed {
+				continue
+			}
+			d := v.Decision[0]
+			key := seenKey{peer: peer, decision: d.Key}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if d.Key.Identity != peer {
+				d.Packets = 0
+				d.Bytes = 0
+			}
+			d.Key.Identity = peer
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// collectExceptedIdentities returns the identities denied at the port although identities without entries are allowed.
+func collectExceptedIdentities(policies []proxy.PolicyEntry, egress bool, protocol uint8, port uint16) []uint32 {
+	ret := make([]uint32, 0)
+	for _, p := range policies {
+		if p.IsEgress() != egress || p.Key.Identity == 0 || slices.Contains(ret, p.Key.Identity) {
+			continue
+		}
+		if !proxy.ComputeVerdict(policies, egress, p.Key.Identity, protocol, port).Allowed {
+			ret = append(ret, p.Key.Identity)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// isWorldIdentity reports whether the identity stands for the peers outside the cluster.
+func isWorldIdentity(id uint32) bool {
+	switch identity.NumericIdentity(id) {
+	case identity.ReservedIdentityWorld, identity.ReservedIdentityWorldIPv4, identity.ReservedIdentityWorldIPv6:
+		return true
+	}
+	return false
+}
+
+// runReachPeers lists every peer that the pod may reach (egress) or may be reached from (ingress).
+// The verdict of the peer side is computed on an example endpoint of the peer identity,
+// because endpoints with the same identity share the same policy.
+func runReachPeers(ctx context.Context, stdout, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, target types.NamespacedName, egress, hasPort bool) error {
+	protocol, err := parseReachProtocol()
+	if err != nil {
+		return err
+	}
+
+	pod, err := clientset.CoreV1().Pods(target.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	podIdentity, err := getPodIdentity(ctx, dynamicClient, target.Namespace, target.Name)
+	if err != nil {
+		return err
+	}
+
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return err
+	}
+
+	reachable := collectReachablePolicies(policies, egress, hasPort, protocol, reachOptions.port)
+	entries, err := makeInspectEntries(ctx, client, clientset, dynamicClient, nil, target.String(), pod.Spec.NodeName, reachable)
+	if err != nil {
+		return err
+	}
+
+	role := trafficRoleReceiver
+	if egress {
+		role = trafficRoleSender
+	}
+
+	peerVerdicts := make(map[reachPeerVerdictKey]*reachVerdictSide)
+	arr := make([]reachPeerEntry, len(entries))
+	for i, e := range entries {
+		entry := reachPeerEntry{
+			inspectEntry: e,
+			Role:         role,
+			PeerVerdict:  "-",
+		}
+
+		example, err := getIdentityExample(ctx, dynamicClient, e.Identity)
+		if err != nil {
+			return err
+		}
+		outside := false
+		switch {
+		case e.Identity == 0:
+			entry.Workload = "any identity"
+			entry.Peers = make([]string, 0)
+			if hasPort {
+				for _, id := range collectExceptedIdentities(policies, egress, protocol, reachOptions.port) {
+					entry.Peers = append(entry.Peers, fmt.Sprintf("except %d", id))
+				}
+			}
+			entry.PeerReason = "peer is any identity without its own entries"
+		case example == nil && (strings.HasPrefix(e.Example, "cidr:") || strings.HasPrefix(e.Example, "fqdn:") || isWorldIdentity(e.Identity)):
+			outside = true
+			entry.Workload = e.Example
+			entry.Peers = []string{e.Example}
+			entry.PeerReason = "peer is outside the cluster"
+		case example == nil:
+			entry.Workload = e.Example
+			entry.PeerReason = "peer has no endpoint"
+		case !hasPort && (e.WildcardProtocol || e.WildcardPort):
+			entry.PeerReason = "specify --port to evaluate the peer"
+		}
+
+		if example != nil {
+			peers, err := getIdentityPeers(ctx, clientset, dynamicClient, e.Identity)
+			if err != nil {
+				return err
+			}
+			entry.Workload = peers.Workload
+			entry.EndpointCount = len(peers.Endpoints)
+			entry.Peers = peers.Endpoints
+		}
+
+		evaluated := entry.PeerReason == ""
+		if evaluated {
+			key := reachPeerVerdictKey{identity: e.Identity, protocol: e.Protocol, port: e.Port}
+			if hasPort {
+				key.protocol, key.port = protocol, reachOptions.port
+			}
+			side, ok := peerVerdicts[key]
+			if !ok {
+				peerPod, err := clientset.CoreV1().Pods(example.GetNamespace()).Get(ctx, example.GetName(), metav1.GetOptions{})
+				if err != nil {
+					// The example endpoint may be deleted after the identity was looked up
+					entry.PeerReason = fmt.Sprintf("failed to get the example endpoint: %v", err)
+					evaluated = false
+				} else {
+					side, err = computeReachVerdictOnPod(ctx, stderr, clientset, dynamicClient, peerPod, !egress, podIdentity, key.protocol, key.port)
+					if err != nil {
+						return err
+					}
+					peerVerdicts[key] = side
+				}
+			}
+			if evaluated {
+				entry.PeerVerdict = side.Verdict
+				entry.PeerReason = side.Reason
+			}
+		}
+
+		switch {
+		case entry.PeerVerdict == verdictDenied:
+			entry.Verdict = verdictDenied
+		case evaluated || outside:
+			entry.Verdict = verdictAllowed
+		default:
+			// The peer may still drop the traffic
+			entry.Verdict = verdictUnknown
+		}
+		arr[i] = entry
+	}
+
+	header := []string{"ROLE", "DIRECTION", "|", "IDENTITY", "NAMESPACE", "WORKLOAD", "ENDPOINTS:", "PEERS", "|", "PROTOCOL", "PORT", "|", "PEER-VERDICT", "VERDICT"}
+	return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
+		p := arr[index]
+		protocol := u8proto.U8proto(p.Protocol).String()
+		var port string
+		if p.WildcardPort {
+			port = "ANY"
+		} else {
+			port = fmt.Sprint(p.Port)
+		}
+		peers := p.Peers
+		if (rootOptions.output == OutputSimple) && strings.HasPrefix(p.Workload, "cidr:") {
+			expr := strings.Replace(p.Workload, "+", ",    +", -1)
+			expr = strings.Replace(expr, "-", ",    -", -1)
+			peers = strings.Split(expr, ",")
+		}
+		return []any{p.Role, p.Direction, "|", p.Identity, p.Namespace, p.Workload, p.EndpointCount, peers, "|", protocol, port, "|", p.PeerVerdict, p.Verdict}
+	})
+}
@@ -0,0 +1,305 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/u8proto"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const servicePrefix = "svc/"
+
+// reachServiceBackend is the verdict for a single backend pod behind a service port.
+type reachServiceBackend struct {
+	ServicePort string `json:"service_port"`
+	Port        int32  `json:"port"`
+	NodePort    int32  `json:"node_port,omitempty"`
+	Backend     string `json:"backend"`
+	TargetPort  uint16 `json:"target_port"`
+	Protocol    string `json:"protocol"`
+	Sender      string `json:"sender"`
+	Receiver    string `json:"receiver"`
+	Verdict     string `json:"verdict"`
+}
+
+// reachServicePort aggregates the verdicts of all backends behind a service port.
+type reachServicePort struct {
+	ServicePort string `json:"service_port"`
+	Port        int32  `json:"port"`
+	NodePort    int32  `json:"node_port,omitempty"`
+	Protocol    string `json:"protocol"`
+	Backends    int    `json:"backends"`
+	Allowed     int    `json:"allowed"`
+	Verdict     string `json:"verdict"`
+}
+
+type reachServiceResult struct {
+	Service  string                `json:"service"`
+	Backends []reachServiceBackend `json:"backends"`
+	Ports    []reachServicePort    `json:"ports"`
+}
+
+// parseServiceTarget parses "svc/NAMESPACE/NAME[:PORT]", where PORT is a service port name or number.
+func parseServiceTarget(s string) (types.NamespacedName, string, error) {
+	s = strings.TrimPrefix(s, servicePrefix)
+	s, port, _ := strings.Cut(s, ":")
+	nn, err := parseNamespacedName(s)
+	if err != nil {
+		return types.NamespacedName{}, "", errors.New("--to should be specified as svc/NAMESPACE/NAME[:PORT]")
+	}
+	return nn, port, nil
+}
+
+func matchServicePort(sp *corev1.ServicePort, port string) bool {
+	return port == "" || port == sp.Name || port == strconv.Itoa(int(sp.Port))
+}
+
+type reachServiceBackendKey struct {
+	pod      types.NamespacedName
+	protocol uint8
+	port     uint16
+}
+
+// runReachService evaluates the reachability from a pod to every backend of a service.
+// Service ports are mapped to target ports through EndpointSlices, so named target ports are resolved per backend.
+func runReachService(ctx context.Context, stdout, stderr io.Writer) error {
+	if reachOptions.from == "" {
+		return errors.New("--from must be specified with a service")
+	}
+	from, err := parseNamespacedName(reachOptions.from)
+	if err != nil {
+		return errors.New("--from should be specified as NAMESPACE/POD")
+	}
+	target, portSpec, err := parseServiceTarget(reachOptions.to)
+	if err != nil {
+		return err
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	svc, err := clientset.CoreV1().Services(target.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	servicePorts := slices.DeleteFunc(slices.Clone(svc.Spec.Ports), func(sp corev1.ServicePort) bool {
+		return !matchServicePort(&sp, portSpec)
+	})
+	if len(servicePorts) == 0 {
+		return fmt.Errorf("service %s does not have port %s", target, portSpec)
+	}
+
+	sliceList, err := clientset.DiscoveryV1().EndpointSlices(target.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + target.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	fromPod, err := clientset.CoreV1().Pods(from.Namespace).Get(ctx, from.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	fromIdentity, err := getPodIdentity(ctx, dynamicClient, from.Namespace, from.Name)
+	if err != nil {
+		return err
+	}
+
+	result := reachServiceResult{
+		Service:  target.String(),
+		Backends: make([]reachServiceBackend, 0),
+		Ports:    make([]reachServicePort, 0),
+	}
+	cache := make(map[reachServiceBackendKey]*reachServiceBackend)
+	for _, sp := range servicePorts {
+		agg := reachServicePort{
+			ServicePort: sp.Name,
+			Port:        sp.Port,
+			NodePort:    sp.NodePort,
+			Protocol:    string(sp.Protocol),
+		}
+		if agg.ServicePort == "" {
+			agg.ServicePort = strconv.Itoa(int(sp.Port))
+		}
+		// A backend appears in multiple EndpointSlices in dual-stack clusters.
+		seen := make(map[reachServiceBackendKey]bool)
+
+		for _, slice := range sliceList.Items {
+			var targetPort *discoveryv1.EndpointPort
+			for _, p := range slice.Ports {
+				name := ""
+				if p.Name != nil {
+					name = *p.Name
+				}
+				if name == sp.Name && p.Port != nil {
+					targetPort = &p
+					break
+				}
+			}
+			if targetPort == nil {
+				continue
+			}
+			protocol := corev1.ProtocolTCP
+			if targetPort.Protocol != nil {
+				protocol = *targetPort.Protocol
+			}
+			proto, err := u8proto.ParseProtocol(string(protocol))
+			if err != nil {
+				return err
+			}
+
+			for _, ep := range slice.Endpoints {
+				if ep.TargetRef == nil || ep.TargetRef.Kind != "Pod" {
+					continue
+				}
+				// Endpoints which are not ready do not receive traffic through the service; nil means ready
+				if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+					continue
+				}
+				key := reachServiceBackendKey{
+					pod:      types.NamespacedName{Namespace: ep.TargetRef.Namespace, Name: ep.TargetRef.Name},
+					protocol: uint8(proto),
+					port:     uint16(*targetPort.Port),
+				}
+				if key.pod.Namespace == "" {
+					key.pod.Namespace = slice.Namespace
+				}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				backend, ok := cache[key]
+				if !ok {
+					backend, err = computeReachServiceBackend(ctx, stderr, clientset, dynamicClient, fromPod, fromIdentity, key)
+					if err != nil {
+						return err
+					}
+					cache[key] = backend
+				}
+				if backend == nil {
+					continue
+				}
+				b := *backend
+				b.ServicePort = agg.ServicePort
+				b.Port = sp.Port
+				b.NodePort = sp.NodePort
+				result.Backends = append(result.Backends, b)
+
+				agg.Backends++
+				if b.Verdict == verdictAllowed {
+					agg.Allowed++
+				}
+			}
+		}
+
+		switch {
+		case agg.Backends == 0:
+			agg.Verdict = "-"
+		case agg.Allowed == agg.Backends:
+			agg.Verdict = verdictAllowed
+		case agg.Allowed == 0:
+			agg.Verdict = verdictDenied
+		default:
+			agg.Verdict = verdictPartial
+		}
+		result.Ports = append(result.Ports, agg)
+	}
+
+	if err := writeReachService(stdout, &result); err != nil {
+		return err
+	}
+	if reachOptions.check {
+		for _, p := range result.Ports {
+			if p.Verdict != verdictAllowed {
+				return errTrafficDenied
+			}
+		}
+	}
+	return nil
+}
+
+// computeReachServiceBackend evaluates the verdict between the sender and a backend pod.
+// It returns nil if the backend pod is already deleted, so that the backend is skipped.
+func computeReachServiceBackend(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, fromPod *corev1.Pod, fromIdentity uint32, key reachServiceBackendKey) (*reachServiceBackend, error) {
+	toPod, err := clientset.CoreV1().Pods(key.pod.Namespace).Get(ctx, key.pod.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		fmt.Fprintf(stderr, "Warning: skipped backend %s: %v\n", key.pod, err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	toIdentity, err := getPodIdentity(ctx, dynamicClient, key.pod.Namespace, key.pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := computeReachVerdictOnPod(ctx, stderr, clientset, dynamicClient, fromPod, true, toIdentity, key.protocol, key.port)
+	if err != nil {
+		return nil, err
+	}
+	receiver, err := computeReachVerdictOnPod(ctx, stderr, clientset, dynamicClient, toPod, false, fromIdentity, key.protocol, key.port)
+	if err != nil {
+		return nil, err
+	}
+	v := makeReachVerdict(key.protocol, key.port, []reachVerdictSide{*sender, *receiver})
+
+	return &reachServiceBackend{
+		Backend:    key.pod.String(),
+		TargetPort: key.port,
+		Protocol:   v.Protocol,
+		Sender:     sender.Verdict,
+		Receiver:   receiver.Verdict,
+		Verdict:    v.Verdict,
+	}, nil
+}
+
+func writeReachService(w io.Writer, result *reachServiceResult) error {
+	rows := make([]reachServiceBackend, 0, len(result.Backends)+len(result.Ports))
+	for _, p := range result.Ports {
+		for _, b := range result.Backends {
+			if b.ServicePort == p.ServicePort {
+				rows = append(rows, b)
+			}
+		}
+		rows = append(rows, reachServiceBackend{
+			ServicePort: p.ServicePort,
+			Port:        p.Port,
+			NodePort:    p.NodePort,
+			Backend:     fmt.Sprintf("(%d/%d backends allowed)", p.Allowed, p.Backends),
+			Protocol:    p.Protocol,
+			Sender:      "-",
+			Receiver:    "-",
+			Verdict:     p.Verdict,
+		})
+	}
+
+	header := []string{"SERVICE-PORT", "PORT", "NODE-PORT", "|", "BACKEND", "TARGET-PORT", "PROTOCOL", "|", "SENDER", "RECEIVER", "VERDICT"}
+	return writeSimpleOrJson(w, result, header, len(rows), func(index int) []any {
+		p := rows[index]
+		nodePort := "-"
+		if p.NodePort != 0 {
+			nodePort = strconv.Itoa(int(p.NodePort))
+		}
+		targetPort := "-"
+		if p.TargetPort != 0 {
+			targetPort = strconv.Itoa(int(p.TargetPort))
+		}
+		return []any{p.ServicePort, p.Port, nodePort, "|", p.Backend, targetPort, p.Protocol, "|", p.Sender, p.Receiver, p.Verdict}
+	})
+}
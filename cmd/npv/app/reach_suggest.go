@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/u8proto"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// writeReachSuggestion writes the minimal CiliumNetworkPolicies which would allow the traffic.
+// An allow rule cannot override a deny rule, so explicitly denied sides are reported as comments instead.
+func writeReachSuggestion(ctx context.Context, w io.Writer, dynamicClient *dynamic.DynamicClient, from, to types.NamespacedName, v *reachVerdict) error {
+	if v.isAllowed() {
+		_, err := fmt.Fprintf(w, "# %s/%d from %s to %s is already allowed\n", v.Protocol, v.Port, from, to)
+		return err
+	}
+
+	toPorts := []any{
+		map[string]any{
+			"ports": []any{
+				map[string]any{
+					"port":     strconv.Itoa(int(v.Port)),
+					"protocol": v.Protocol,
+				},
+			},
+		},
+	}
+
+	first := true
+	for _, s := range v.Sides {
+		if s.Verdict == verdictAllowed {
+			continue
+		}
+		if !first {
+			if _, err := fmt.Fprintln(w, "---"); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		egress := s.Role == trafficRoleSender
+		if s.isDeniedExplicitly() {
+			by := "a deny rule"
+			if len(s.DenyPolicies) > 0 {
+				by = strings.Join(s.DenyPolicies, ", ")
+			}
+			_, err := fmt.Fprintf(w, "# %s %s is denied explicitly by %s; the deny rule should be removed or narrowed\n",
+				s.Subject, strings.ToLower(s.Direction), by)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		sub, obj := from, to
+		if !egress {
+			sub, obj = to, from
+		}
+		manifest, err := makePolicyManifest(ctx, dynamicClient, sub, obj, egress, false, "", toPorts)
+		if err != nil {
+			return err
+		}
+		manifest.SetName(fmt.Sprintf("%s-%s-%d", manifest.GetName(), strings.ToLower(v.Protocol), v.Port))
+
+		data, err := yaml.Marshal(manifest.Object)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s", string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isSuggestibleProtocol(protocol uint8) bool {
+	switch u8proto.U8proto(protocol) {
+	case u8proto.TCP, u8proto.UDP, u8proto.SCTP:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/u8proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+)
+
+var errTrafficDenied = errors.New("traffic is denied")
+
+type reachVerdictSide struct {
+	Role      string         `json:"role"`
+	Subject   string         `json:"subject"`
+	Direction string         `json:"direction"`
+	Enforced  bool           `json:"enforced"`
+	Audit     bool           `json:"audit"`
+	Verdict   string         `json:"verdict"`
+	Reason    string         `json:"reason"`
+	Entries   []inspectEntry `json:"entries"`
+
+	// DenyPolicies lists the policies which have deny rules on the port when the traffic is denied explicitly.
+	DenyPolicies []string `json:"deny_policies,omitempty"`
+}
+
+type reachVerdict struct {
+	Protocol string             `json:"protocol"`
+	Port     uint16             `json:"port"`
+	Sides    []reachVerdictSide `json:"sides"`
+	Verdict  string             `json:"verdict"`
+}
+
+// isDeniedExplicitly reports whether the side is decided by a deny entry.
+// DenyPolicies may be empty even in this case, because it is found heuristically.
+func (s *reachVerdictSide) isDeniedExplicitly() bool {
+	return s.Verdict == verdictDenied && len(s.Entries) > 0 && s.Entries[0].Policy == policyDeny
+}
+
+func (v *reachVerdict) isAllowed() bool {
+	return v.Verdict == verdictAllowed
+}
+
+func parseReachProtocol() (uint8, error) {
+	proto, err := u8proto.ParseProtocol(reachOptions.protocol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse --protocol: %w", err)
+	}
+	return uint8(proto), nil
+}
+
+// isPolicyEnforced reports whether policy enforcement is active for the direction, and whether it runs in audit mode.
+func isPolicyEnforced(enabled models.EndpointPolicyEnabled, egress bool) (enforced, audit bool) {
+	mode := string(enabled)
+	audit = strings.HasPrefix(mode, "audit-")
+	mode = strings.TrimPrefix(mode, "audit-")
+
+	switch mode {
+	case string(models.EndpointPolicyEnabledBoth):
+		enforced = true
+	case string(models.EndpointPolicyEnabledIngress):
+		enforced = !egress
+	case string(models.EndpointPolicyEnabledEgress):
+		enforced = egress
+	}
+	return enforced, audit && enforced
+}
+
+// computeReachVerdictOnPod decides whether the pod sends (egress) or receives (ingress) the traffic to/from the peer identity.
+func computeReachVerdictOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod, egress bool, peer uint32, protocol uint8, port uint16) (*reachVerdictSide, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+
+	response, err := client.GetEndpointResponse(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint info: %w", err)
+	}
+
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &reachVerdictSide{
+		Role:      trafficRoleReceiver,
+		Subject:   pod.Namespace + "/" + pod.Name,
+		Direction: directionIngress,
+	}
+	if egress {
+		ret.Role = trafficRoleSender
+		ret.Direction = directionEgress
+	}
+	ret.Enforced, ret.Audit = isPolicyEnforced(response.Payload.Status.Policy.Realized.PolicyEnabled, egress)
+
+	v := proxy.ComputeVerdict(policies, egress, peer, protocol, port)
+	ret.Entries, err = makeInspectEntries(ctx, client, clientset, dynamicClient, nil, ret.Subject, pod.Spec.NodeName, v.Decision)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !ret.Enforced:
+		ret.Verdict = verdictAllowed
+		ret.Reason = "policy enforcement is disabled"
+	case v.Allowed:
+		ret.Verdict = verdictAllowed
+		ret.Reason = "allowed by policy"
+	case ret.Audit:
+		ret.Verdict = verdictAllowed
+		ret.Reason = "denied by policy, but audit mode is enabled"
+	case len(v.Decision) > 0:
+		ret.Verdict = verdictDenied
+		ret.Reason = "denied by policy"
+		ret.DenyPolicies = findDenyPolicies(response.Payload.Status.Policy.Realized.L4, egress, protocol, port)
+	default:
+		ret.Verdict = verdictDenied
+		ret.Reason = "no policy allows the traffic"
+	}
+	return ret, nil
+}
+
+// findDenyPolicies returns the names of the policies which have deny rules on the port.
+// The map entries do not tell where they came from, so the realized rules are examined instead.
+// Selectors are not evaluated, so a policy denying other peers on the same port may be listed too.
+func findDenyPolicies(l4 *models.L4Policy, egress bool, protocol uint8, port uint16) []string {
+	names := make(map[string]struct{})
+	for _, r := range expandRealizedRules(l4, !egress, egress) {
+		if !r.IsDeny() || !r.Filter.matches(protocol, port) {
+			continue
+		}
+		for _, d := range r.DerivedFrom {
+			e := parseListEntry("", "", d)
+			name := e.Kind + " " + e.Name
+			if e.Namespace != "-" {
+				name = e.Kind + " " + e.Namespace + "/" + e.Name
+			}
+			names[name] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(names))
+}
+
+func makeReachVerdict(protocol uint8, port uint16, sides []reachVerdictSide) *reachVerdict {
+	ret := &reachVerdict{
+		Protocol: u8proto.U8proto(protocol).String(),
+		Port:     port,
+		Sides:    sides,
+		Verdict:  verdictAllowed,
+	}
+	for _, s := range sides {
+		if s.Verdict != verdictAllowed {
+			ret.Verdict = verdictDenied
+		}
+	}
+	return ret
+}
+
+func writeReachVerdict(w io.Writer, v *reachVerdict) error {
+	rows := append(v.Sides, reachVerdictSide{
+		Role:    trafficRoleOverall,
+		Verdict: v.Verdict,
+	})
+
+	header := []string{"ROLE", "DIRECTION", "VERDICT", "REASON", "|", "POLICY", "IDENTITY", "EXAMPLE-ENDPOINT", "PROTOCOL", "PORT"}
+	return writeSimpleOrJson(w, v, header, len(rows), func(index int) []any {
+		p := rows[index]
+		direction := p.Direction
+		if direction == "" {
+			direction = "-"
+		}
+
+		policies := make([]string, len(p.Entries))
+		identities := make([]string, len(p.Entries))
+		examples := make([]string, len(p.Entries))
+		protocols := make([]string, len(p.Entries))
+		ports := make([]string, len(p.Entries))
+		for i, e := range p.Entries {
+			policies[i] = e.Policy
+			identities[i] = fmt.Sprint(e.Identity)
+			examples[i] = e.Example
+			protocols[i] = u8proto.U8proto(e.Protocol).String()
+			if e.WildcardPort {
+				ports[i] = "ANY"
+			} else {
+				ports[i] = fmt.Sprint(e.Port)
+			}
+		}
+		return []any{p.Role, direction, p.Verdict, p.Reason, "|", policies, identities, examples, protocols, ports}
+	})
+}
+
+func runReachVerdict(ctx context.Context, stdout, stderr io.Writer) error {
+	if reachOptions.from == "" || reachOptions.to == "" {
+		return errors.New("both --from and --to must be specified to compute the verdict")
+	}
+	from, err := parseNamespacedName(reachOptions.from)
+	if err != nil {
+		return errors.New("--from should be specified as NAMESPACE/POD")
+	}
+	to, err := parseNamespacedName(reachOptions.to)
+	if err != nil {
+		return errors.New("--to should be specified as NAMESPACE/POD")
+	}
+	protocol, err := parseReachProtocol()
+	if err != nil {
+		return err
+	}
+	if reachOptions.suggest && !isSuggestibleProtocol(protocol) {
+		return errors.New("--suggest supports only tcp, udp, and sctp")
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	fromPod, err := clientset.CoreV1().Pods(from.Namespace).Get(ctx, from.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	toPod, err := clientset.CoreV1().Pods(to.Namespace).Get(ctx, to.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	fromIdentity, err := getPodIdentity(ctx, dynamicClient, from.Namespace, from.Name)
+	if err != nil {
+		return err
+	}
+	toIdentity, err := getPodIdentity(ctx, dynamicClient, to.Namespace, to.Name)
+	if err != nil {
+		return err
+	}
+
+	sender, err := computeReachVerdictOnPod(ctx, stderr, clientset, dynamicClient, fromPod, true, toIdentity, protocol, reachOptions.port)
+	if err != nil {
+		return err
+	}
+	receiver, err := computeReachVerdictOnPod(ctx, stderr, clientset, dynamicClient, toPod, false, fromIdentity, protocol, reachOptions.port)
+	if err != nil {
+		return err
+	}
+
+	verdict := makeReachVerdict(protocol, reachOptions.port, []reachVerdictSide{*sender, *receiver})
+	if reachOptions.suggest {
+		err = writeReachSuggestion(ctx, stdout, dynamicClient, from, to, verdict)
+	} else {
+		err = writeReachVerdict(stdout, verdict)
+	}
+	if err != nil {
+		return err
+	}
+	if reachOptions.check && !verdict.isAllowed() {
+		return errTrafficDenied
+	}
+	return nil
+}
@@ -17,6 +17,10 @@ import (
 const (
 	OutputJson   = "json"
 	OutputSimple = "simple"
+
+	// OutputCSV and OutputHTML are supported only by commands that render a matrix.
+	OutputCSV  = "csv"
+	OutputHTML = "html"
 )
 
 const (
@@ -34,6 +38,9 @@ const (
 	flagNamespace         = "namespace"
 	flagPodSelector       = "selector"
 	flagNode              = "node"
+	flagSample            = "sample"
+	flagHost              = "host"
+	flagIncludePending    = "include-pending"
 )
 
 var rootOptions struct {
@@ -100,6 +107,9 @@ func fillSelectorOptions(cmd *cobra.Command) error {
 		if num > 1 {
 			return errors.New("at most one of --all-namespaces, --namespace-selector, --namespace, and --node may be specified")
 		}
+		if cmd.Flags().Changed(flagHost) && (num > 0 || cmd.Flags().Changed(flagPodSelector) || cmd.Flags().Changed(flagSample)) {
+			return errors.New("--host cannot be used with pod selector options")
+		}
 	}
 
 	if cmd.Flags().Lookup(flagAllNamespaces) != nil {
@@ -142,6 +152,28 @@ func fillSelectorOptions(cmd *cobra.Command) error {
 		config.Node = v
 	}
 
+	if cmd.Flags().Lookup(flagSample) != nil {
+		v, err := cmd.Flags().GetString(flagSample)
+		if err != nil {
+			return err
+		}
+		sample, err := subject.ParseSample(v)
+		if err != nil {
+			return err
+		}
+		config.Sample = sample
+	} else {
+		config.Sample = subject.SampleNone
+	}
+
+	if cmd.Flags().Lookup(flagIncludePending) != nil {
+		v, err := cmd.Flags().GetBool(flagIncludePending)
+		if err != nil {
+			return err
+		}
+		config.IncludePending = v
+	}
+
 	if config.Node != "" {
 		config.AllNamespaces = true
 	}
@@ -151,7 +183,9 @@ func fillSelectorOptions(cmd *cobra.Command) error {
 }
 
 var commonOptions struct {
-	with cidrOptions
+	with        cidrOptions
+	host        string
+	showSkipped bool
 }
 
 var policyOptions struct {
@@ -196,7 +230,7 @@ func init() {
 }
 
 func addGroupOption(cmd *cobra.Command) {
-	cmd.Flags().StringP(flagGroup, "g", "pod", "merge entries within each subject group (pod [p], ns [n], all [a])")
+	cmd.Flags().StringP(flagGroup, "g", "pod", "merge entries within each subject group (pod [p], identity [i], label=KEY, ns [n], all [a])")
 }
 
 // Use addNamespaceOption, addNamespaceSelectorOption, or addPodSelectorOption,
@@ -221,6 +255,14 @@ func addPodSelectorOption(cmd *cobra.Command) {
 	cmd.Flags().StringP(flagPodSelector, "l", "", "pod label selector")
 	cmd.Flags().String(flagNode, "", "node to filter pods by; implies -A (--all-namespaces)")
 	cmd.RegisterFlagCompletionFunc(flagNode, completeNodes)
+	cmd.Flags().Bool(flagIncludePending, false, "include pending pods whose endpoints already exist")
+	cmd.Flags().BoolVar(&commonOptions.showSkipped, "show-skipped", false, "list skipped pods with reasons on stderr")
+}
+
+// addSampleOption adds a flag for querying one pod per identity.
+// Only commands that report the other pods as members should have this flag.
+func addSampleOption(cmd *cobra.Command) {
+	cmd.Flags().String(flagSample, subject.SampleNone, "query one representative pod per identity and report the others as members (none, node, cluster)")
 }
 
 func addDirectionOption(cmd *cobra.Command) {
@@ -228,6 +270,13 @@ func addDirectionOption(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&policyOptions.egress, "egress", false, "show egress-rules only")
 }
 
+// addHostOption adds a flag for selecting the host endpoint of a node instead of pods.
+// Host-networked pods share the host endpoint of their node.
+func addHostOption(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&commonOptions.host, flagHost, "", "node whose host endpoint should be examined instead of pods")
+	cmd.RegisterFlagCompletionFunc(flagHost, completeNodes)
+}
+
 func addWithCIDROptions(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&commonOptions.with.cidrs, "with-cidrs", "", "show rules for CIDRs")
 	cmd.Flags().BoolVar(&commonOptions.with.privateCIDRs, "with-private-cidrs", false, "show rules for private CIDRs (10.0.0.0/8,172.16.0.0/12,192.168.0.0/16)")
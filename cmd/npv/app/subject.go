@@ -14,6 +14,7 @@ import (
 func init() {
 	addGroupOption(subjectCmd)
 	addPodSelectorOption(subjectCmd)
+	addSampleOption(subjectCmd)
 	rootCmd.AddCommand(subjectCmd)
 }
 
@@ -25,28 +26,58 @@ var subjectCmd = &cobra.Command{
 	Args: cobra.RangeArgs(0, 1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
-			return runSubject(context.Background(), cmd.OutOrStdout(), "")
+			return runSubject(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), "")
 		} else {
-			return runSubject(context.Background(), cmd.OutOrStdout(), args[0])
+			return runSubject(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
 		}
 	},
 	ValidArgsFunction: completePods,
 }
 
-func runSubject(ctx context.Context, stdout io.Writer, name string) error {
-	clientset, _, err := createK8sClients()
+type subjectEntry struct {
+	Subject        string   `json:"subject"`
+	Representative string   `json:"representative"`
+	Members        []string `json:"members"`
+}
+
+func runSubject(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	clientset, dynamicClient, err := createK8sClients()
 	if err != nil {
 		return fmt.Errorf("failed to create k8s clients: %w", err)
 	}
 
-	pods, err := subject.ListSubjectPods(ctx, clientset, name)
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
 	if err != nil {
 		return err
 	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return err
+	}
+
+	if subject.GetSelectorConfig().Sample != subject.SampleNone {
+		arr := make([]subjectEntry, len(pods))
+		for i, p := range pods {
+			members := subject.GetPodMembers(p)
+			arr[i] = subjectEntry{
+				Subject:        subject.GetPodSubject(p),
+				Representative: p.Namespace + "/" + p.Name,
+				Members:        make([]string, len(members)),
+			}
+			for j, m := range members {
+				arr[i].Members[j] = m.String()
+			}
+		}
+
+		header := []string{"SUBJECT", "REPRESENTATIVE", "MEMBERS"}
+		return writeSimpleOrJson(stdout, arr, header, len(arr), func(index int) []any {
+			p := arr[index]
+			return []any{p.Subject, p.Representative, p.Members}
+		})
+	}
 
 	subjects := make([]string, len(pods))
 	for i, p := range pods {
-		subjects[i] = subject.GetPodSubject(p.Namespace, p.Name)
+		subjects[i] = subject.GetPodSubject(p)
 	}
 	subjects = slices.Unique(subjects)
 
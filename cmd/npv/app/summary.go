@@ -84,15 +84,13 @@ func runSummaryOnPod(ctx context.Context, stderr io.Writer, clientset *kubernete
 	return &entry, nil
 }
 
-func runSummary(ctx context.Context, stdout, stderr io.Writer, name string) error {
-	clientset, dynamicClient, err := createK8sClients()
+func runSummaryOnPods(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, name string) ([]summaryEntry, error) {
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	pods, err := subject.ListSubjectPods(ctx, clientset, name)
-	if err != nil {
-		return err
+	if err := reportSkippedPods(stderr); err != nil {
+		return nil, err
 	}
 
 	summary := mapNodeReduce(pods,
@@ -115,6 +113,19 @@ func runSummary(ctx context.Context, stdout, stderr io.Writer, name string) erro
 		},
 	)
 	sort.Slice(summary, func(i, j int) bool { return lessSummaryEntry(&summary[i], &summary[j]) })
+	return summary, nil
+}
+
+func runSummary(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	summary, err := runSummaryOnPods(ctx, stderr, clientset, dynamicClient, name)
+	if err != nil {
+		return err
+	}
 
 	header := []string{"NAMESPACE", "NAME", "INGRESS-ALLOW", "INGRESS-DENY", "EGRESS-ALLOW", "EGRESS-DENY"}
 	return writeSimpleOrJson(stdout, summary, header, len(summary), func(index int) []any {
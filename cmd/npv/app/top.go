@@ -0,0 +1,13 @@
+package app
+
+import "github.com/spf13/cobra"
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+}
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Rank traffic counters of policy maps",
+	Long:  `Rank traffic counters of policy maps`,
+}
@@ -0,0 +1,303 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+const (
+	topByEntry   = "entry"
+	topBySubject = "subject"
+	topByPeer    = "peer"
+	topByPort    = "port"
+)
+
+var topDropsOptions struct {
+	by       string
+	limit    int
+	interval time.Duration
+}
+
+func init() {
+	addPodSelectorOption(topDropsCmd)
+	topDropsCmd.Flags().StringVar(&topDropsOptions.by, "by", topByEntry, "rank by entry, subject, peer, or port")
+	topDropsCmd.Flags().IntVar(&topDropsOptions.limit, "limit", 20, "maximum number of rows; 0 means unlimited")
+	topDropsCmd.Flags().DurationVar(&topDropsOptions.interval, "interval", 0, "sample the counters twice with this interval and show rates instead of lifetime totals")
+	topDropsCmd.RegisterFlagCompletionFunc("by", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{topByEntry, topBySubject, topByPeer, topByPort}, cobra.ShellCompDirectiveNoFileComp
+	})
+	topCmd.AddCommand(topDropsCmd)
+}
+
+var topDropsCmd = &cobra.Command{
+	Use:   "drops [POD]",
+	Short: "Rank denied traffic",
+	Long: `Rank denied traffic
+
+Deny entries of policy maps count the packets dropped by them. The counters are summed up by the key specified
+with --by and ranked by packets. Counters are totals since the entries were created unless --interval is given.`,
+
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runTopDrops(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), "")
+		} else {
+			return runTopDrops(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr(), args[0])
+		}
+	},
+	ValidArgsFunction: completePods,
+}
+
+// topDropsKey identifies a deny entry on a pod.
+// Locally-scoped identities differ among nodes, so the peer is kept as a resolved string.
+type topDropsKey struct {
+	Namespace string
+	Name      string
+	Direction string
+	Peer      string
+	Port      string
+}
+
+type topDropsCounter struct {
+	Bytes   uint64
+	Packets uint64
+}
+
+type topDropsEntry struct {
+	Namespace        string  `json:"namespace,omitempty"`
+	Name             string  `json:"name,omitempty"`
+	Direction        string  `json:"direction,omitempty"`
+	Peer             string  `json:"peer,omitempty"`
+	Port             string  `json:"port,omitempty"`
+	Packets          uint64  `json:"packets"`
+	Bytes            uint64  `json:"bytes"`
+	PacketsPerSecond float64 `json:"packets_per_second,omitempty"`
+	BytesPerSecond   float64 `json:"bytes_per_second,omitempty"`
+}
+
+func (k topDropsKey) project(by string) topDropsKey {
+	switch by {
+	case topBySubject:
+		return topDropsKey{Namespace: k.Namespace, Name: k.Name}
+	case topByPeer:
+		return topDropsKey{Direction: k.Direction, Peer: k.Peer}
+	case topByPort:
+		return topDropsKey{Direction: k.Direction, Port: k.Port}
+	default:
+		return k
+	}
+}
+
+// collectTopDropsOnPod sums up the counters of the deny entries of a pod.
+// When samples are given, the counters are replaced with the increase since the sample of the pod.
+func collectTopDropsOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod, samples map[types.NamespacedName][]proxy.PolicyEntry) (map[topDropsKey]topDropsCounter, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	if samples != nil {
+		before, ok := samples[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]
+		if !ok {
+			return nil, fmt.Errorf("failed to sample the policy map of %s/%s at the beginning of the interval", pod.Namespace, pod.Name)
+		}
+		policies = proxy.SubtractPolicyMap(before, policies)
+	}
+
+	ret := make(map[topDropsKey]topDropsCounter)
+	for i := range policies {
+		p := &policies[i]
+		if !p.IsDeny() || p.Packets == 0 {
+			continue
+		}
+		key := topDropsKey{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Direction: directionIngress,
+			Peer:      describeTopDropsPeer(ctx, stderr, client, clientset, dynamicClient, p.Key.Identity),
+			Port:      makeReachMatrixRule(p).String(),
+		}
+		if p.IsEgress() {
+			key.Direction = directionEgress
+		}
+		c := ret[key]
+		c.Bytes += p.Bytes
+		c.Packets += p.Packets
+		ret[key] = c
+	}
+	return ret, nil
+}
+
+// describeTopDropsPeer resolves the peer of a deny entry.
+// The counters are still worth showing when the peer cannot be resolved, so it falls back to the numeric identity.
+func describeTopDropsPeer(ctx context.Context, stderr io.Writer, client *proxy.Client, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, id uint32) string {
+	if identity.NumericIdentity(id).HasLocalScope() {
+		peer, err := describeLocalIdentity(ctx, client, id)
+		if err == nil {
+			return peer
+		}
+		fmt.Fprintf(stderr, "Warning: %v\n", err)
+	} else {
+		peer, err := describeIdentity(ctx, clientset, dynamicClient, id)
+		if err == nil {
+			return peer
+		}
+		fmt.Fprintf(stderr, "Warning: %v\n", err)
+	}
+	return fmt.Sprintf("identity:%d", id)
+}
+
+func collectTopDrops(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pods []*corev1.Pod, samples map[types.NamespacedName][]proxy.PolicyEntry) map[topDropsKey]topDropsCounter {
+	return mapNodeReduce(pods,
+		func() map[topDropsKey]topDropsCounter {
+			return make(map[topDropsKey]topDropsCounter)
+		},
+		func(pod *corev1.Pod) map[topDropsKey]topDropsCounter {
+			counters, err := collectTopDropsOnPod(ctx, stderr, clientset, dynamicClient, pod, samples)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return counters
+		},
+		func(x, y map[topDropsKey]topDropsCounter) map[topDropsKey]topDropsCounter {
+			maps.Copy(x, y)
+			return x
+		},
+	)
+}
+
+func runTopDrops(ctx context.Context, stdout, stderr io.Writer, name string) error {
+	switch topDropsOptions.by {
+	case topByEntry, topBySubject, topByPeer, topByPort:
+	default:
+		return fmt.Errorf("--by should be one of %s, %s, %s, %s", topByEntry, topBySubject, topByPeer, topByPort)
+	}
+	if topDropsOptions.limit < 0 {
+		return errors.New("--limit should not be negative")
+	}
+	if topDropsOptions.interval < 0 {
+		return errors.New("--interval should not be negative")
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, name)
+	if err != nil {
+		return err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return err
+	}
+
+	var samples map[types.NamespacedName][]proxy.PolicyEntry
+	if topDropsOptions.interval > 0 {
+		samples = samplePolicyMaps(ctx, stderr, clientset, dynamicClient, pods)
+		time.Sleep(topDropsOptions.interval)
+	}
+	counters := collectTopDrops(ctx, stderr, clientset, dynamicClient, pods, samples)
+
+	aggregated := make(map[topDropsKey]topDropsCounter)
+	for k, v := range counters {
+		k = k.project(topDropsOptions.by)
+		c := aggregated[k]
+		c.Bytes += v.Bytes
+		c.Packets += v.Packets
+		aggregated[k] = c
+	}
+
+	arr := make([]topDropsEntry, 0, len(aggregated))
+	for k, v := range aggregated {
+		entry := topDropsEntry{
+			Namespace: k.Namespace,
+			Name:      k.Name,
+			Direction: k.Direction,
+			Peer:      k.Peer,
+			Port:      k.Port,
+			Packets:   v.Packets,
+			Bytes:     v.Bytes,
+		}
+		if topDropsOptions.interval > 0 {
+			entry.PacketsPerSecond = float64(v.Packets) / topDropsOptions.interval.Seconds()
+			entry.BytesPerSecond = float64(v.Bytes) / topDropsOptions.interval.Seconds()
+		}
+		arr = append(arr, entry)
+	}
+	slices.SortFunc(arr, func(x, y topDropsEntry) int {
+		return cmp.Or(
+			-cmp.Compare(x.Packets, y.Packets),
+			-cmp.Compare(x.Bytes, y.Bytes),
+			strings.Compare(x.Namespace, y.Namespace),
+			strings.Compare(x.Name, y.Name),
+			strings.Compare(x.Direction, y.Direction),
+			strings.Compare(x.Peer, y.Peer),
+			strings.Compare(x.Port, y.Port),
+		)
+	})
+	if topDropsOptions.limit > 0 && len(arr) > topDropsOptions.limit {
+		arr = arr[:topDropsOptions.limit]
+	}
+
+	return writeTopDrops(stdout, arr)
+}
+
+func writeTopDrops(w io.Writer, arr []topDropsEntry) error {
+	var header []string
+	switch topDropsOptions.by {
+	case topBySubject:
+		header = []string{"NAMESPACE", "NAME"}
+	case topByPeer:
+		header = []string{"DIRECTION", "PEER"}
+	case topByPort:
+		header = []string{"DIRECTION", "PORT"}
+	default:
+		header = []string{"NAMESPACE", "NAME", "|", "DIRECTION", "PEER", "PORT"}
+	}
+	if topDropsOptions.interval > 0 {
+		header = append(header, "|", "PACKETS/S:", "BYTES/S:")
+	} else {
+		header = append(header, "|", "PACKETS:", "BYTES:")
+	}
+
+	return writeSimpleOrJson(w, arr, header, len(arr), func(index int) []any {
+		p := arr[index]
+		var values []any
+		switch topDropsOptions.by {
+		case topBySubject:
+			values = []any{p.Namespace, p.Name}
+		case topByPeer:
+			values = []any{p.Direction, p.Peer}
+		case topByPort:
+			values = []any{p.Direction, p.Port}
+		default:
+			values = []any{p.Namespace, p.Name, "|", p.Direction, p.Peer, p.Port}
+		}
+		if topDropsOptions.interval > 0 {
+			return append(values, "|", fmt.Sprintf("%.2f", p.PacketsPerSecond), fmt.Sprintf("%.2f", p.BytesPerSecond))
+		}
+		return append(values, "|", formatWithUnits(p.Packets), formatWithUnits(p.Bytes))
+	})
+}
@@ -0,0 +1,330 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cilium/cilium/pkg/identity"
+	"github.com/cilium/cilium/pkg/maps/policymap"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+	"github.com/cybozu-go/network-policy-viewer/pkg/subject"
+)
+
+func init() {
+	addPodSelectorOption(verifyConsistencyCmd)
+	addSampleOption(verifyConsistencyCmd)
+	verifyCmd.AddCommand(verifyConsistencyCmd)
+}
+
+var verifyConsistencyCmd = &cobra.Command{
+	Use:   "consistency",
+	Short: "Compare policy maps of pods with the same identity",
+	Long: `Compare policy maps of pods with the same identity
+
+Pods with the same identity should have identical policy maps regardless of their nodes.
+The keys of the policy maps are compared within each identity, and entries found on some pods but not on others
+are listed with the nodes and their agent versions. Peers with node-local CIDR identities are compared by their CIDRs.
+Peers which cannot be resolved are shown as identity:N.
+Entries for IPs selected by toFQDNs rules are excluded, because they depend on the DNS responses seen by each node.
+Use --sample=node to query one pod per identity and node. The second table summarizes the comparison per identity,
+where MEMBERS counts the pods represented by the queried pods.`,
+
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVerifyConsistency(context.Background(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// consistencyKey identifies a policy map entry independently of the node.
+// Node-local identities in the key are replaced with 0 and their CIDRs are kept instead.
+type consistencyKey struct {
+	Key    policymap.PolicyKey
+	Policy string
+	CIDR   string
+}
+
+func (k consistencyKey) entry() *proxy.PolicyEntry {
+	return &proxy.PolicyEntry{PolicyEntryDump: policymap.PolicyEntryDump{Key: k.Key}}
+}
+
+type consistencySample struct {
+	Namespace string
+	Name      string
+	Node      string
+	Version   string
+	Identity  uint32
+	Members   int
+	Keys      map[consistencyKey]struct{}
+}
+
+type consistencyNode struct {
+	Node    string `json:"node"`
+	Version string `json:"version"`
+}
+
+func (n consistencyNode) String() string {
+	return fmt.Sprintf("%s (%s)", n.Node, n.Version)
+}
+
+type consistencyEntry struct {
+	Identity  uint32            `json:"identity"`
+	Direction string            `json:"direction"`
+	Policy    string            `json:"policy"`
+	Peer      string            `json:"peer"`
+	Port      string            `json:"port"`
+	PresentOn []consistencyNode `json:"present_on"`
+	MissingOn []consistencyNode `json:"missing_on"`
+}
+
+type consistencyIdentity struct {
+	Identity     uint32 `json:"identity"`
+	Pods         int    `json:"pods"`
+	Members      int    `json:"members"`
+	Nodes        int    `json:"nodes"`
+	Inconsistent int    `json:"inconsistent"`
+}
+
+type consistencyResult struct {
+	Entries    []consistencyEntry    `json:"entries"`
+	Identities []consistencyIdentity `json:"identities"`
+}
+
+func runVerifyConsistencyOnPod(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, pod *corev1.Pod) (*consistencySample, error) {
+	client, err := proxy.CreateCiliumClient(ctx, stderr, clientset, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cilium client: %w", err)
+	}
+	version, err := client.GetAgentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := getPodIdentity(ctx, dynamicClient, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := client.QueryPolicyMap(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[consistencyKey]struct{}, len(policies))
+	for _, p := range policies {
+		key := consistencyKey{
+			Key:    p.Key,
+			Policy: policyAllow,
+		}
+		if p.IsDeny() {
+			key.Policy = policyDeny
+		}
+		// The numbers of CIDR identities are allocated by each agent, so they differ across nodes
+		if identity.NumericIdentity(p.Key.Identity).HasLocalScope() {
+			// The IPs selected by toFQDNs rules depend on the DNS responses seen by each node, so they are not compared
+			selectors, err := client.GetFQDNSelectorsForIdentity(ctx, p.Key.Identity)
+			if err != nil {
+				return nil, err
+			}
+			if len(selectors) > 0 {
+				continue
+			}
+			key.Key.Identity = 0
+			s, err := client.GetCIDRForIdentity(ctx, p.Key.Identity)
+			if err != nil {
+				// Keep the entry, so that the rest of the policy map is still compared
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				key.CIDR = fmt.Sprintf("identity:%d", p.Key.Identity)
+			} else {
+				key.CIDR = s.String()
+			}
+		}
+		keys[key] = struct{}{}
+	}
+
+	return &consistencySample{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Node:      pod.Spec.NodeName,
+		Version:   version,
+		Identity:  id,
+		Members:   len(subject.GetPodMembers(pod)),
+		Keys:      keys,
+	}, nil
+}
+
+// collectConsistencyNodes returns the nodes of the samples, sorted and deduplicated.
+func collectConsistencyNodes(samples []*consistencySample) []consistencyNode {
+	ret := make([]consistencyNode, 0, len(samples))
+	for _, s := range samples {
+		ret = append(ret, consistencyNode{Node: s.Node, Version: s.Version})
+	}
+	slices.SortFunc(ret, func(x, y consistencyNode) int { return strings.Compare(x.Node, y.Node) })
+	return slices.Compact(ret)
+}
+
+func compareConsistencyKey(x, y consistencyKey) int {
+	return cmp.Or(
+		cmp.Compare(x.Key.TrafficDirection, y.Key.TrafficDirection),
+		strings.Compare(x.Policy, y.Policy),
+		cmp.Compare(x.Key.Identity, y.Key.Identity),
+		strings.Compare(x.CIDR, y.CIDR),
+		cmp.Compare(x.Key.Nexthdr, y.Key.Nexthdr),
+		cmp.Compare(x.Key.GetDestPort(), y.Key.GetDestPort()),
+		cmp.Compare(x.Key.Prefixlen, y.Key.Prefixlen),
+	)
+}
+
+func verifyConsistency(ctx context.Context, stderr io.Writer, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, samples []*consistencySample) (*consistencyResult, error) {
+	groups := make(map[uint32][]*consistencySample)
+	for _, s := range samples {
+		groups[s.Identity] = append(groups[s.Identity], s)
+	}
+
+	result := consistencyResult{
+		Entries:    make([]consistencyEntry, 0),
+		Identities: make([]consistencyIdentity, 0),
+	}
+	peers := make(map[uint32]string)
+	for _, id := range slices.Sorted(maps.Keys(groups)) {
+		group := groups[id]
+		summary := consistencyIdentity{
+			Identity: id,
+			Pods:     len(group),
+			Nodes:    len(collectConsistencyNodes(group)),
+		}
+		for _, s := range group {
+			summary.Members += s.Members
+		}
+
+		union := make(map[consistencyKey]struct{})
+		for _, s := range group {
+			maps.Copy(union, s.Keys)
+		}
+		for _, key := range slices.SortedFunc(maps.Keys(union), compareConsistencyKey) {
+			present := make([]*consistencySample, 0)
+			missing := make([]*consistencySample, 0)
+			for _, s := range group {
+				if _, ok := s.Keys[key]; ok {
+					present = append(present, s)
+				} else {
+					missing = append(missing, s)
+				}
+			}
+			if len(missing) == 0 {
+				continue
+			}
+
+			peer := key.CIDR
+			if peer == "" {
+				if _, ok := peers[key.Key.Identity]; !ok {
+					desc, err := describeIdentity(ctx, clientset, dynamicClient, key.Key.Identity)
+					if err != nil {
+						fmt.Fprintf(stderr, "Warning: %v\n", err)
+						desc = fmt.Sprintf("identity:%d", key.Key.Identity)
+					}
+					peers[key.Key.Identity] = desc
+				}
+				peer = peers[key.Key.Identity]
+			}
+			direction := directionIngress
+			if key.entry().IsEgress() {
+				direction = directionEgress
+			}
+			result.Entries = append(result.Entries, consistencyEntry{
+				Identity:  id,
+				Direction: direction,
+				Policy:    key.Policy,
+				Peer:      peer,
+				Port:      makeReachMatrixRule(key.entry()).String(),
+				PresentOn: collectConsistencyNodes(present),
+				MissingOn: collectConsistencyNodes(missing),
+			})
+			summary.Inconsistent++
+		}
+		result.Identities = append(result.Identities, summary)
+	}
+	return &result, nil
+}
+
+func runVerifyConsistency(ctx context.Context, stdout, stderr io.Writer) error {
+	if subject.GetSelectorConfig().Sample == subject.SampleCluster {
+		return errors.New("--sample=cluster leaves no pods to compare; use --sample=node instead")
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	pods, err := subject.ListSubjectPods(ctx, clientset, dynamicClient, "")
+	if err != nil {
+		return err
+	}
+	if err := reportSkippedPods(stderr); err != nil {
+		return err
+	}
+
+	samples := mapNodeReduce(pods,
+		func() []*consistencySample {
+			return make([]*consistencySample, 0)
+		},
+		func(pod *corev1.Pod) []*consistencySample {
+			sample, err := runVerifyConsistencyOnPod(ctx, stderr, clientset, dynamicClient, pod)
+			if err != nil {
+				fmt.Fprintf(stderr, "Warning: %v\n", err)
+				return nil
+			}
+			return []*consistencySample{sample}
+		},
+		func(x, y []*consistencySample) []*consistencySample {
+			return append(x, y...)
+		},
+	)
+
+	result, err := verifyConsistency(ctx, stderr, clientset, dynamicClient, samples)
+	if err != nil {
+		return err
+	}
+	return writeVerifyConsistency(stdout, result)
+}
+
+func writeVerifyConsistency(w io.Writer, result *consistencyResult) error {
+	if rootOptions.output == OutputJson {
+		return writeSimpleOrJson(w, result, nil, 0, nil)
+	}
+
+	header := []string{"IDENTITY:", "|", "DIRECTION", "POLICY", "PEER", "PORT", "|", "PRESENT-ON", "MISSING-ON"}
+	err := writeSimpleOrJson(w, result, header, len(result.Entries), func(index int) []any {
+		p := result.Entries[index]
+		present := make([]string, len(p.PresentOn))
+		for i, n := range p.PresentOn {
+			present[i] = n.String()
+		}
+		missing := make([]string, len(p.MissingOn))
+		for i, n := range p.MissingOn {
+			missing[i] = n.String()
+		}
+		return []any{p.Identity, "|", p.Direction, p.Policy, p.Peer, p.Port, "|", present, missing}
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+
+	header = []string{"IDENTITY:", "PODS:", "MEMBERS:", "NODES:", "INCONSISTENT:"}
+	return writeSimpleOrJson(w, result, header, len(result.Identities), func(index int) []any {
+		p := result.Identities[index]
+		return []any{p.Identity, p.Pods, p.Members, p.Nodes, p.Inconsistent}
+	})
+}
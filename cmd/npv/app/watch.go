@@ -0,0 +1,447 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cilium/cilium/pkg/u8proto"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
+)
+
+const (
+	watchModeInspect = "inspect"
+	watchModeSummary = "summary"
+
+	watchColorNew     = 32
+	watchColorChanged = 33
+	watchColorRemoved = 31
+)
+
+var watchOptions struct {
+	mode    string
+	refresh time.Duration
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&watchOptions.mode, "mode", watchModeInspect, "command to refresh (inspect, summary)")
+	watchCmd.Flags().DurationVar(&watchOptions.refresh, "refresh", 5*time.Second, "refresh interval")
+	addGroupOption(watchCmd)
+	addPodSelectorOption(watchCmd)
+	addDirectionOption(watchCmd)
+	rootCmd.AddCommand(watchCmd)
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [POD]",
+	Short: "Keep refreshing inspect or summary in a terminal",
+	Long: `Keep refreshing inspect or summary in a terminal
+
+Entries are compared with the previous refresh. New entries are shown in green, entries whose counters
+changed in yellow, and removed entries in red until the next refresh. RATE is the increase of the counters
+per second since the previous refresh.
+
+Keys:
+  s  toggle sorting by rate
+  /  filter entries by a substring; press Enter to apply or Esc to cancel
+  r  refresh now
+  q  quit`,
+
+	Args: cobra.RangeArgs(0, 1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runWatch(context.Background(), cmd.OutOrStdout(), "")
+		} else {
+			return runWatch(context.Background(), cmd.OutOrStdout(), args[0])
+		}
+	},
+	ValidArgsFunction: completePods,
+}
+
+// watchRow is an entry of inspect or summary flattened for comparison between refreshes.
+type watchRow struct {
+	Key      string
+	Cells    []string
+	Counters []uint64
+	Rate     float64
+	Color    int
+}
+
+type watchSource struct {
+	header   []string
+	counters []string
+	rateUnit string
+	// rate computes the value to be shown as RATE from the increase of the counters.
+	rate  func(delta []int64) int64
+	fetch func(ctx context.Context, stderr io.Writer) ([]watchRow, error)
+}
+
+func makeWatchInspectSource(clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, name string) *watchSource {
+	filter := proxy.MakeBasicFilter(policyOptions.ingress, policyOptions.egress, true, true, true, true)
+	return &watchSource{
+		header:   []string{"SUBJECT", "POLICY", "DIRECTION", "IDENTITY", "NAMESPACE", "EXAMPLE-ENDPOINT", "PROTOCOL", "PORT"},
+		counters: []string{"BYTES", "REQUESTS"},
+		rateUnit: "BYTES/S",
+		rate:     func(delta []int64) int64 { return delta[0] },
+		fetch: func(ctx context.Context, stderr io.Writer) ([]watchRow, error) {
+			arr, err := runInspectOnPods(ctx, stderr, clientset, dynamicClient, filter, name)
+			if err != nil {
+				return nil, err
+			}
+			ret := make([]watchRow, len(arr))
+			for i, p := range arr {
+				port := "ANY"
+				if !p.WildcardPort {
+					port = fmt.Sprint(p.Port)
+				}
+				cells := []string{p.Subject, p.Policy, p.Direction, fmt.Sprint(p.Identity), p.Namespace, p.Example, u8proto.U8proto(p.Protocol).String(), port}
+				ret[i] = watchRow{
+					Key:      strings.Join(cells, "\t"),
+					Cells:    cells,
+					Counters: []uint64{p.Bytes, p.Requests},
+				}
+			}
+			return ret, nil
+		},
+	}
+}
+
+func makeWatchSummarySource(clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, name string) *watchSource {
+	return &watchSource{
+		header:   []string{"NAMESPACE", "NAME"},
+		counters: []string{"INGRESS-ALLOW", "INGRESS-DENY", "EGRESS-ALLOW", "EGRESS-DENY"},
+		rateUnit: "CHANGES/S",
+		rate: func(delta []int64) int64 {
+			var ret int64
+			for _, d := range delta {
+				ret += max(d, -d)
+			}
+			return ret
+		},
+		fetch: func(ctx context.Context, stderr io.Writer) ([]watchRow, error) {
+			arr, err := runSummaryOnPods(ctx, stderr, clientset, dynamicClient, name)
+			if err != nil {
+				return nil, err
+			}
+			ret := make([]watchRow, len(arr))
+			for i, p := range arr {
+				cells := []string{p.Namespace, p.Name}
+				ret[i] = watchRow{
+					Key:      strings.Join(cells, "\t"),
+					Cells:    cells,
+					Counters: []uint64{uint64(p.IngressAllow), uint64(p.IngressDeny), uint64(p.EgressAllow), uint64(p.EgressDeny)},
+				}
+			}
+			return ret, nil
+		},
+	}
+}
+
+// watchWriter keeps the last line written by concurrent warnings, so that they do not break the screen.
+type watchWriter struct {
+	mu   sync.Mutex
+	last string
+}
+
+func (w *watchWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if line := strings.TrimSpace(string(p)); line != "" {
+		w.last = line
+	}
+	return len(p), nil
+}
+
+func (w *watchWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last
+}
+
+type watchResult struct {
+	rows []watchRow
+	time time.Time
+	err  error
+}
+
+type watchState struct {
+	source     *watchSource
+	rows       []watchRow
+	previous   map[string]watchRow
+	updated    time.Time
+	sortByRate bool
+	filter     string
+	editing    bool
+	input      string
+	err        error
+}
+
+// update compares the rows with the previous refresh, and keeps removed rows until the next refresh.
+func (s *watchState) update(result *watchResult) {
+	s.err = result.err
+	if result.err != nil {
+		return
+	}
+
+	elapsed := result.time.Sub(s.updated).Seconds()
+	current := make(map[string]watchRow, len(result.rows))
+	rows := make([]watchRow, 0, len(result.rows))
+	for _, r := range result.rows {
+		current[r.Key] = r
+		prev, ok := s.previous[r.Key]
+		switch {
+		case s.previous == nil:
+		case !ok:
+			r.Color = watchColorNew
+		case !slices.Equal(prev.Counters, r.Counters):
+			r.Color = watchColorChanged
+			delta := make([]int64, len(r.Counters))
+			for i := range r.Counters {
+				delta[i] = int64(r.Counters[i]) - int64(prev.Counters[i])
+			}
+			r.Rate = float64(s.source.rate(delta)) / elapsed
+		}
+		rows = append(rows, r)
+	}
+	for _, r := range s.rows {
+		if _, ok := current[r.Key]; !ok && r.Color != watchColorRemoved {
+			r.Color = watchColorRemoved
+			r.Rate = 0
+			rows = append(rows, r)
+		}
+	}
+
+	s.rows = rows
+	s.previous = current
+	s.updated = result.time
+}
+
+func (s *watchState) visibleRows() []watchRow {
+	ret := make([]watchRow, 0, len(s.rows))
+	filter := strings.ToLower(s.filter)
+	for _, r := range s.rows {
+		if filter == "" || strings.Contains(strings.ToLower(strings.Join(r.Cells, " ")), filter) {
+			ret = append(ret, r)
+		}
+	}
+	slices.SortStableFunc(ret, func(x, y watchRow) int {
+		if s.sortByRate {
+			if ret := -cmp.Compare(x.Rate, y.Rate); ret != 0 {
+				return ret
+			}
+		}
+		return strings.Compare(x.Key, y.Key)
+	})
+	return ret
+}
+
+// render draws the whole screen. The terminal is in raw mode, so lines end with CRLF.
+func (s *watchState) render(w io.Writer, status string) error {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H\x1b[2J")
+	sortKey := "key"
+	if s.sortByRate {
+		sortKey = "rate"
+	}
+	fmt.Fprintf(&buf, "npv watch %s  refresh: %s  sort: %s  filter: %q  updated: %s\r\n",
+		watchOptions.mode, watchOptions.refresh, sortKey, s.filter, s.updated.Format(time.TimeOnly))
+	switch {
+	case s.editing:
+		fmt.Fprintf(&buf, "/%s\r\n", s.input)
+	case s.err != nil:
+		fmt.Fprintf(&buf, "Error: %v\r\n", s.err)
+	default:
+		fmt.Fprintf(&buf, "%s\r\n", status)
+	}
+
+	rows := s.visibleRows()
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 1, 1, ' ', 0)
+	header := slices.Concat(s.source.header, s.source.counters, []string{s.source.rateUnit})
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, r := range rows {
+		values := slices.Clone(r.Cells)
+		for _, c := range r.Counters {
+			values = append(values, formatWithUnits(c))
+		}
+		values = append(values, fmt.Sprintf("%.1f", r.Rate))
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	for i, line := range lines {
+		if i >= height-2 {
+			break
+		}
+		if len(line) > width {
+			line = line[:width]
+		}
+		if i > 0 && rows[i-1].Color != 0 {
+			line = fmt.Sprintf("\x1b[1;%dm%s\x1b[0m", rows[i-1].Color, line)
+		}
+		buf.WriteString(line)
+		if i < height-3 {
+			buf.WriteString("\r\n")
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// handleKey processes a key stroke and reports whether to quit and whether to refresh.
+func (s *watchState) handleKey(key byte) (quit, refresh bool) {
+	if s.editing {
+		switch key {
+		case '\r', '\n':
+			s.filter = s.input
+			s.editing = false
+		case 0x1b:
+			s.editing = false
+		case 0x7f, 0x08:
+			if len(s.input) > 0 {
+				s.input = s.input[:len(s.input)-1]
+			}
+		case 0x03:
+			return true, false
+		default:
+			if key >= 0x20 && key < 0x7f {
+				s.input += string(key)
+			}
+		}
+		return false, false
+	}
+
+	switch key {
+	case 'q', 0x03:
+		return true, false
+	case 's':
+		s.sortByRate = !s.sortByRate
+	case '/':
+		s.editing = true
+		s.input = s.filter
+	case 'r':
+		return false, true
+	}
+	return false, false
+}
+
+func runWatch(ctx context.Context, stdout io.Writer, name string) error {
+	if watchOptions.refresh <= 0 {
+		return errors.New("--refresh should be positive")
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("watch requires a terminal")
+	}
+
+	clientset, dynamicClient, err := createK8sClients()
+	if err != nil {
+		return err
+	}
+
+	var source *watchSource
+	switch watchOptions.mode {
+	case watchModeInspect:
+		source = makeWatchInspectSource(clientset, dynamicClient, name)
+	case watchModeSummary:
+		source = makeWatchSummarySource(clientset, dynamicClient, name)
+	default:
+		return fmt.Errorf("--mode should be one of: %s, %s", watchModeInspect, watchModeSummary)
+	}
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), oldState)
+	// Use the alternate screen and restore the original one on exit
+	fmt.Fprint(stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(stdout, "\x1b[?25h\x1b[?1049l")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	keys := make(chan byte)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(buf); err != nil {
+				return
+			}
+			select {
+			case keys <- buf[0]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	stderr := &watchWriter{}
+	results := make(chan watchResult)
+	fetching := false
+	fetch := func() {
+		if fetching {
+			return
+		}
+		fetching = true
+		go func() {
+			resetK8sCaches()
+			rows, err := source.fetch(ctx, stderr)
+			select {
+			case results <- watchResult{rows: rows, time: time.Now(), err: err}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	state := &watchState{source: source}
+	ticker := time.NewTicker(watchOptions.refresh)
+	defer ticker.Stop()
+	fetch()
+	for {
+		status := stderr.String()
+		if fetching {
+			status = "refreshing... " + status
+		}
+		if err := state.render(stdout, status); err != nil {
+			return err
+		}
+
+		select {
+		case key := <-keys:
+			quit, refresh := state.handleKey(key)
+			if quit {
+				return nil
+			}
+			if refresh {
+				fetch()
+			}
+		case <-ticker.C:
+			fetch()
+		case result := <-results:
+			fetching = false
+			state.update(&result)
+		}
+	}
+}
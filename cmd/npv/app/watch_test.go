@@ -0,0 +1,192 @@
+package app
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func makeTestWatchState() *watchState {
+	return &watchState{
+		source: &watchSource{
+			rate: func(delta []int64) int64 { return delta[0] },
+		},
+	}
+}
+
+func makeTestWatchRow(key string, counter uint64) watchRow {
+	return watchRow{Key: key, Cells: []string{key}, Counters: []uint64{counter}}
+}
+
+func watchRowKeys(rows []watchRow) []string {
+	ret := make([]string, len(rows))
+	for i, r := range rows {
+		ret[i] = r.Key
+	}
+	return ret
+}
+
+func TestWatchStateUpdate(t *testing.T) {
+	s := makeTestWatchState()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s.update(&watchResult{
+		rows: []watchRow{makeTestWatchRow("a", 100), makeTestWatchRow("b", 100)},
+		time: start,
+	})
+	for _, r := range s.rows {
+		if r.Color != 0 || r.Rate != 0 {
+			t.Errorf("row %s on the first refresh: color=%d, rate=%f", r.Key, r.Color, r.Rate)
+		}
+	}
+
+	s.update(&watchResult{
+		rows: []watchRow{makeTestWatchRow("a", 100), makeTestWatchRow("b", 300), makeTestWatchRow("c", 10)},
+		time: start.Add(2 * time.Second),
+	})
+	s.update(&watchResult{
+		rows: []watchRow{makeTestWatchRow("b", 300), makeTestWatchRow("c", 10)},
+		time: start.Add(4 * time.Second),
+	})
+	expected := map[string]watchRow{
+		"a": {Color: watchColorRemoved},
+		"b": {},
+		"c": {},
+	}
+	if keys := watchRowKeys(s.rows); !slices.Equal(keys, []string{"b", "c", "a"}) {
+		t.Fatalf("unexpected rows after removal: %v", keys)
+	}
+	for _, r := range s.rows {
+		if e := expected[r.Key]; r.Color != e.Color || r.Rate != e.Rate {
+			t.Errorf("row %s after removal: color=%d, rate=%f", r.Key, r.Color, r.Rate)
+		}
+	}
+
+	// a removed row is shown only until the next refresh
+	s.update(&watchResult{
+		rows: []watchRow{makeTestWatchRow("b", 300), makeTestWatchRow("c", 10)},
+		time: start.Add(6 * time.Second),
+	})
+	if keys := watchRowKeys(s.rows); !slices.Equal(keys, []string{"b", "c"}) {
+		t.Errorf("unexpected rows after the removed row expired: %v", keys)
+	}
+}
+
+func TestWatchStateUpdateColors(t *testing.T) {
+	s := makeTestWatchState()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s.update(&watchResult{
+		rows: []watchRow{makeTestWatchRow("a", 100), makeTestWatchRow("b", 100)},
+		time: start,
+	})
+	s.update(&watchResult{
+		rows: []watchRow{makeTestWatchRow("b", 300), makeTestWatchRow("c", 10)},
+		time: start.Add(2 * time.Second),
+	})
+
+	expected := map[string]watchRow{
+		"a": {Color: watchColorRemoved},
+		"b": {Color: watchColorChanged, Rate: 100},
+		"c": {Color: watchColorNew},
+	}
+	if keys := watchRowKeys(s.rows); !slices.Equal(keys, []string{"b", "c", "a"}) {
+		t.Fatalf("unexpected rows: %v", keys)
+	}
+	for _, r := range s.rows {
+		if e := expected[r.Key]; r.Color != e.Color || r.Rate != e.Rate {
+			t.Errorf("row %s: color=%d, rate=%f, expected color=%d, rate=%f", r.Key, r.Color, r.Rate, e.Color, e.Rate)
+		}
+	}
+}
+
+func TestWatchStateUpdateError(t *testing.T) {
+	s := makeTestWatchState()
+	s.update(&watchResult{rows: []watchRow{makeTestWatchRow("a", 100)}, time: time.Now()})
+
+	s.update(&watchResult{err: errors.New("failed")})
+	if s.err == nil {
+		t.Error("error is not kept")
+	}
+	if keys := watchRowKeys(s.rows); !slices.Equal(keys, []string{"a"}) {
+		t.Errorf("rows are changed on error: %v", keys)
+	}
+}
+
+func TestWatchStateVisibleRows(t *testing.T) {
+	s := makeTestWatchState()
+	s.rows = []watchRow{
+		{Key: "c", Cells: []string{"test", "Pod-C"}, Rate: 10},
+		{Key: "a", Cells: []string{"test", "pod-a"}, Rate: 1},
+		{Key: "b", Cells: []string{"other", "pod-b"}, Rate: 100},
+	}
+
+	testCases := []struct {
+		sortByRate bool
+		filter     string
+		expected   []string
+	}{
+		{expected: []string{"a", "b", "c"}},
+		{sortByRate: true, expected: []string{"b", "c", "a"}},
+		{filter: "TEST", expected: []string{"a", "c"}},
+		{sortByRate: true, filter: "pod-c", expected: []string{"c"}},
+		{filter: "none", expected: []string{}},
+	}
+	for _, tc := range testCases {
+		s.sortByRate = tc.sortByRate
+		s.filter = tc.filter
+		if keys := watchRowKeys(s.visibleRows()); !slices.Equal(keys, tc.expected) {
+			t.Errorf("sortByRate=%v, filter=%q: expected %v, got %v", tc.sortByRate, tc.filter, tc.expected, keys)
+		}
+	}
+}
+
+func TestWatchStateHandleKey(t *testing.T) {
+	s := makeTestWatchState()
+
+	if quit, refresh := s.handleKey('s'); quit || refresh || !s.sortByRate {
+		t.Errorf("s: quit=%v, refresh=%v, sortByRate=%v", quit, refresh, s.sortByRate)
+	}
+	if s.handleKey('s'); s.sortByRate {
+		t.Error("s does not toggle sorting back")
+	}
+
+	// Enter applies the filter
+	s.handleKey('/')
+	if !s.editing {
+		t.Fatal("/ does not start editing")
+	}
+	for _, k := range []byte("podx") {
+		s.handleKey(k)
+	}
+	s.handleKey(0x7f)
+	if s.filter != "" || s.input != "pod" {
+		t.Errorf("filter is applied while editing: filter=%q, input=%q", s.filter, s.input)
+	}
+	if quit, _ := s.handleKey('q'); quit {
+		t.Error("q quits while editing")
+	}
+	s.handleKey('\r')
+	if s.editing || s.filter != "podq" {
+		t.Errorf("Enter: editing=%v, filter=%q", s.editing, s.filter)
+	}
+
+	// Esc cancels editing and keeps the current filter
+	s.handleKey('/')
+	if s.input != "podq" {
+		t.Errorf("/ does not start from the current filter: %q", s.input)
+	}
+	s.handleKey('x')
+	s.handleKey(0x1b)
+	if s.editing || s.filter != "podq" {
+		t.Errorf("Esc: editing=%v, filter=%q", s.editing, s.filter)
+	}
+
+	if quit, refresh := s.handleKey('r'); quit || !refresh {
+		t.Errorf("r: quit=%v, refresh=%v", quit, refresh)
+	}
+	if quit, _ := s.handleKey('q'); !quit {
+		t.Error("q does not quit")
+	}
+}
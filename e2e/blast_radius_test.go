@@ -0,0 +1,25 @@
+package e2e
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testBlastRadius() {
+	It("should list workloads reachable from a pod", func() {
+		podName := "test/" + onePodByLabelSelector(Default, "test", "test=self")
+		result := runViewerSafe(Default, nil, "blast-radius", "-o=json", "--hops=1", podName)
+
+		resultString := string(jqSafe(Default, result, "-r", `.[] | select(.workload == "deploy/l4-ingress-explicit-allow-tcp") | [.hops, .namespace, (.path[0].ports | join(" "))] | @csv`))
+		resultString = strings.TrimSpace(strings.Replace(resultString, `"`, "", -1))
+		Expect(resultString).To(Equal("1,test-l4,TCP/8000"))
+
+		workloads := jqSafe(Default, result, "-r", `.[] | .workload`)
+		Expect(strings.Fields(string(workloads))).NotTo(ContainElement("deploy/l3-ingress-explicit-deny-all"))
+
+		hops := jqSafe(Default, result, "-r", `[.[] | .hops] | unique | .[]`)
+		Expect(strings.Fields(string(hops))).To(Equal([]string{"1"}))
+	})
+}
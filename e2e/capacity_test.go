@@ -0,0 +1,30 @@
+package e2e
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testCapacity() {
+	It("should show utilization of policy maps", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+		result := runViewerSafe(Default, nil, "capacity", "-o=json", "-n=test", podName)
+
+		// The entries are counted in the same way as summary, and the default bpf-policy-map-max is used
+		pod := jqSafe(Default, result, "-r", `.pods[] | [.entries, .max, (.policies | length > 0), (.selectors | length > 0)] | @csv`)
+		Expect(strings.TrimSpace(string(pod))).To(Equal("33,16384,true,true"))
+
+		namespaces := jqSafe(Default, result, "-r", `.namespaces[] | [.namespace, .pods, .entries, .over_threshold] | @csv`)
+		namespacesString := strings.TrimSpace(strings.Replace(string(namespaces), `"`, "", -1))
+		Expect(namespacesString).To(Equal("test,1,33,0"))
+	})
+
+	It("should warn above the threshold", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+		_, stderr, err := runViewer(nil, "capacity", "-n=test", podName, "--threshold=0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(stderr)).To(ContainSubstring("Warning: test/" + podName + " uses 33 of 16384 policy map entries"))
+	})
+}
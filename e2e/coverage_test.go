@@ -0,0 +1,28 @@
+package e2e
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testCoverage() {
+	It("should show enforcement on test pods", func() {
+		// l3-baseline selects all test pods in both directions
+		result := runViewerSafe(Default, nil, "coverage", "-o=json", "-N=group=test")
+		pods := jqSafe(Default, result, "-r", `[.pods[] | .selected and .ingress.enforced and .egress.enforced and (.audit | not)] | [length > 0, all] | @csv`)
+		Expect(strings.TrimSpace(string(pods))).To(Equal("true,true"))
+
+		namespaces := jqSafe(Default, result, "-r", `.namespaces[] | [.namespace, .pods == .selected] | @csv`)
+		namespacesString := strings.TrimSpace(strings.Replace(string(namespaces), `"`, "", -1))
+		Expect(namespacesString).To(Equal("test,true\ntest-l3,true\ntest-l4,true"))
+	})
+
+	It("should report default deny", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+		result := runViewerSafe(Default, nil, "coverage", "-o=json", "-n=test", podName)
+		pod := jqSafe(Default, result, "-r", `.pods[] | [.ingress.default_deny, .egress.default_deny] | @csv`)
+		Expect(strings.TrimSpace(string(pod))).To(Equal("true,true"))
+	})
+}
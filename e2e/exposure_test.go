@@ -0,0 +1,24 @@
+package e2e
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testExposure() {
+	It("should list pods accepting traffic from any identity", func() {
+		result := runViewerSafe(Default, nil, "exposure", "-o=json", "-n=test-l4")
+		result = jqSafe(Default, result, "-r", `[.[] | [.workload, .direction, .peer, .port] | @csv] | unique | .[]`)
+		resultString := strings.TrimSpace(strings.Replace(string(result), `"`, "", -1))
+		Expect(resultString).To(Equal("deploy/l4-ingress-all-allow-tcp,Ingress,ANY,TCP/8000"))
+	})
+
+	It("should not list egress allows on specific ports", func() {
+		// self allows egress to 1.1.1.1 only on DNS ports
+		result := runViewerSafe(Default, nil, "exposure", "-o=json", "-n=test", "-l=test=self")
+		result = jqSafe(Default, result, "-r", `[.[] | select(.direction == "Egress")] | length`)
+		Expect(strings.TrimSpace(string(result))).To(Equal("0"))
+	})
+}
@@ -0,0 +1,31 @@
+package e2e
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testHealthEndpoints() {
+	It("should report no issues on healthy endpoints", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+		result := runViewerSafe(Default, nil, "health", "endpoints", "-o=json", "-n=test", podName)
+
+		endpoints := jqSafe(Default, result, "-r", `.endpoints | length`)
+		Expect(strings.TrimSpace(string(endpoints))).To(Equal("0"))
+
+		nodes := jqSafe(Default, result, "-r", `.nodes[] | [.endpoints, .unhealthy, (.agent_revision > 0)] | @csv`)
+		Expect(strings.TrimSpace(string(nodes))).To(Equal("1,0,true"))
+	})
+
+	It("should show healthy endpoints with --all", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+		result := runViewerSafe(Default, nil, "health", "endpoints", "-o=json", "-n=test", podName, "--all")
+
+		// The realized revision should have caught up with the agent
+		endpoint := jqSafe(Default, result, "-r", `.endpoints[] | [.name, .state, (.realized_revision == .agent_revision), (.issues | length)] | @csv`)
+		endpointString := strings.TrimSpace(strings.Replace(string(endpoint), `"`, "", -1))
+		Expect(endpointString).To(Equal(podName + ",ready,true,0"))
+	})
+}
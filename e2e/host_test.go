@@ -0,0 +1,26 @@
+package e2e
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testHost() {
+	It("should inspect host endpoint", func() {
+		result := runViewerSafe(Default, nil, "inspect", "--host=kind-worker", "-o=json")
+		Expect(string(jqSafe(Default, result, "-r", "type"))).To(Equal("array"))
+
+		result = runViewerSafe(Default, nil, "inspect", "--host=kind-worker", "-o=json", "--ingress")
+		Expect(string(jqSafe(Default, result, "-r", `[.[] | select(.direction != "Ingress")] | length`))).To(Equal("0"))
+	})
+
+	It("should list host policies", func() {
+		result := runViewerSafe(Default, nil, "list", "--host=kind-worker", "-o=json")
+		Expect(string(jqSafe(Default, result, "-r", "type"))).To(Equal("array"))
+	})
+
+	It("should reject --host with pod selectors", func() {
+		_, _, err := runViewer(nil, "inspect", "--host=kind-worker", "-n=test")
+		Expect(err).To(HaveOccurred())
+	})
+}
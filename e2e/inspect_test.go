@@ -418,4 +418,49 @@ Allow,Egress,l4-ingress-explicit-allow-tcp,false,false,6,8000`,
 		// Check
 		Expect(amount12).To(Equal(amount3))
 	})
+
+	It("should show workloads of peers", func() {
+		expected := `l3-ingress-explicit-allow-all,deploy/l3-ingress-explicit-allow-all,2
+l3-ingress-explicit-deny-all,deploy/l3-ingress-explicit-deny-all,1
+l3-ingress-implicit-deny-all,deploy/l3-ingress-implicit-deny-all,1`
+
+		result := runViewerSafe(Default, nil, "inspect", "-o=json", "-n=test", selfNames[0], "--egress", "--allowed", "--peers=workload", "--expand-peers")
+		Expect(string(jqSafe(Default, result, "-r", `[.[] | select(.endpoint_count != (.peers | length))] | length`))).To(Equal("0"))
+
+		result = fixJsonPodField(Default, result, "example_endpoint")
+		result = jqSafe(Default, result, "-r", `.[] | select(.namespace == "test-l3") | [.example_endpoint, .workload, .endpoint_count] | @csv`)
+		resultString := strings.Replace(string(result), `"`, "", -1)
+		Expect(resultString).To(Equal(expected))
+	})
+
+	It("should show traffic within an interval", func() {
+		// Lifetime counters are read later, so they always include the window
+		window := runViewerSafe(Default, nil, "inspect", "-o=json", "-n=test", selfNames[0], "--interval=1s")
+		lifetime := runViewerSafe(Default, nil, "inspect", "-o=json", "-n=test", selfNames[0])
+		Expect(jqSafe(Default, window, "-r", `length`)).To(Equal(jqSafe(Default, lifetime, "-r", `length`)))
+
+		lifetimeBytes, err := strconv.Atoi(strings.TrimSpace(string(jqSafe(Default, lifetime, "-r", `[.[].bytes] | add`))))
+		Expect(err).NotTo(HaveOccurred())
+		windowBytes, err := strconv.Atoi(strings.TrimSpace(string(jqSafe(Default, window, "-r", `[.[].bytes] | add`))))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(windowBytes).To(BeNumerically("<=", lifetimeBytes))
+	})
+
+	It("should report the other pods as members when sampling", func() {
+		result := runViewerSafe(Default, nil, "inspect", "-o=json", "-n=test", "-l=test=self", "--sample=cluster")
+		subjects := jqSafe(Default, result, "-r", `[.[].subject] | unique | .[]`)
+		Expect(strings.Fields(string(subjects))).To(Equal([]string{selfNames[0]}))
+		members := jqSafe(Default, result, "-r", `[.[].members | join(",")] | unique | .[]`)
+		Expect(strings.Fields(string(members))).To(Equal([]string{"test/" + selfNames[1]}))
+
+		// Commands that do not report members should not accept --sample
+		_, _, err := runViewer(nil, "summary", "-n=test", "--sample=cluster")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should choose the same example endpoint between runs", func() {
+		result1 := runViewerSafe(Default, nil, "inspect", "-o=json", "-n=test", selfNames[0])
+		result2 := runViewerSafe(Default, nil, "inspect", "-o=json", "-n=test", selfNames[0])
+		Expect(jqSafe(Default, result1, "-r", `[.[].example_endpoint]`)).To(Equal(jqSafe(Default, result2, "-r", `[.[].example_endpoint]`)))
+	})
 }
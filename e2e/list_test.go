@@ -103,7 +103,8 @@ Ingress,CiliumClusterwideNetworkPolicy,-,l3-baseline`,
 			Selector:  "test=l4-ingress-all-allow-tcp",
 			Expected: `Egress,CiliumClusterwideNetworkPolicy,-,l3-baseline
 Ingress,CiliumClusterwideNetworkPolicy,-,l3-baseline
-Ingress,CiliumNetworkPolicy,test-l4,l4-ingress-all-allow-tcp`,
+Ingress,CiliumNetworkPolicy,test-l4,l4-ingress-all-allow-tcp
+Ingress,NetworkPolicy,test-l4,l4-ingress-all-allow-tcp-native`,
 		},
 		// npv list should handle --ingress and --egress
 		{
@@ -328,4 +329,36 @@ spec:
 		result := strings.TrimSpace(string(runViewerSafe(Default, nil, "list", "-n=test", "-m", podName)))
 		Expect(result).To(Equal(expected), "compare failed.\nactual: %s\nexpected: %s", result, expected)
 	})
+
+	It("should list Kubernetes NetworkPolicy manifests", func() {
+		podName := onePodByLabelSelector(Default, "test-l4", "test=l4-ingress-all-allow-tcp")
+		result := string(runViewerSafe(Default, nil, "list", "-n=test-l4", "-m", podName))
+		Expect(result).To(ContainSubstring("apiVersion: networking.k8s.io/v1\nkind: NetworkPolicy\n"))
+		Expect(result).To(ContainSubstring("name: l4-ingress-all-allow-tcp-native"))
+		Expect(result).To(ContainSubstring("name: l4-ingress-all-allow-tcp\n"))
+	})
+}
+
+func testListRules() {
+	It("should expand policies into realized rules", func() {
+		self := onePodByLabelSelector(Default, "test", "test=self")
+		selfIdentity := string(kubectlSafe(Default, nil, "get", "cep", "-n=test", self, "-o=jsonpath={.status.identity.id}"))
+
+		podName := onePodByLabelSelector(Default, "test-l4", "test=l4-ingress-explicit-allow-tcp")
+		result := runViewerSafe(Default, nil, "list", "-o=json", "--rules", "--ingress", "-n=test-l4", podName)
+		rules := jqSafe(Default, result, "-r", `.[] | select(.name == "l4-ingress-explicit-allow-tcp") | [.direction, .policy, .protocol, .port] | @csv`)
+		rulesString := strings.TrimSpace(strings.Replace(string(rules), `"`, "", -1))
+		Expect(rulesString).To(Equal("Ingress,Allow,TCP,8000"))
+
+		identities := jqSafe(Default, result, "-r", `.[] | select(.name == "l4-ingress-explicit-allow-tcp") | .identities[]`)
+		Expect(strings.Fields(string(identities))).To(ContainElement(selfIdentity))
+	})
+
+	It("should show deny rules", func() {
+		podName := onePodByLabelSelector(Default, "test-l3", "test=l3-ingress-explicit-deny-all")
+		result := runViewerSafe(Default, nil, "list", "-o=json", "--rules", "--ingress", "-n=test-l3", podName)
+		rules := jqSafe(Default, result, "-r", `.[] | select(.name == "l3-ingress-explicit-deny-all") | [.direction, .policy, .protocol, .port] | @csv`)
+		rulesString := strings.TrimSpace(strings.Replace(string(rules), `"`, "", -1))
+		Expect(rulesString).To(Equal("Ingress,Deny,ANY,ANY"))
+	})
 }
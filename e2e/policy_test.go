@@ -0,0 +1,62 @@
+package e2e
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testPolicyShow() {
+	It("should show endpoints selected by a policy", func() {
+		podName := onePodByLabelSelector(Default, "test-l4", "test=l4-ingress-explicit-allow-tcp")
+		result := runViewerSafe(Default, nil, "policy", "show", "-o=json", "cnp/test-l4/l4-ingress-explicit-allow-tcp")
+
+		endpoints := jqSafe(Default, result, "-r", `.endpoints[] | [.namespace, .name, .workload] | @csv`)
+		endpointsString := strings.TrimSpace(strings.Replace(string(endpoints), `"`, "", -1))
+		Expect(endpointsString).To(Equal("test-l4," + podName + ",deploy/l4-ingress-explicit-allow-tcp"))
+
+		rules := jqSafe(Default, result, "-r", `.rules[] | [.direction, .policy, .protocol, .port, .endpoints, .entries > 0] | @csv`)
+		rulesString := strings.TrimSpace(strings.Replace(string(rules), `"`, "", -1))
+		Expect(rulesString).To(Equal("Ingress,Allow,TCP,8000,1,true"))
+	})
+
+	It("should evaluate the selector of a clusterwide policy", func() {
+		result := runViewerSafe(Default, nil, "policy", "show", "-o=json", "ccnp/l3-baseline")
+		workloads := jqSafe(Default, result, "-r", `[.endpoints[].workload] | unique | .[]`)
+		Expect(strings.Fields(string(workloads))).To(ContainElements("deploy/self", "deploy/l4-ingress-all-allow-tcp"))
+	})
+
+	It("should show a Kubernetes NetworkPolicy", func() {
+		result := runViewerSafe(Default, nil, "policy", "show", "-o=json", "netpol/test-l4/l4-ingress-all-allow-tcp-native")
+		workloads := jqSafe(Default, result, "-r", `[.endpoints[].workload] | unique | .[]`)
+		Expect(strings.TrimSpace(string(workloads))).To(Equal("deploy/l4-ingress-all-allow-tcp"))
+
+		rules := jqSafe(Default, result, "-r", `.rules[] | select(.entries > 0) | [.direction, .policy, .protocol, .port] | @csv`)
+		rulesString := strings.TrimSpace(strings.Replace(string(rules), `"`, "", -1))
+		Expect(rulesString).To(Equal("Ingress,Allow,TCP,8000"))
+	})
+}
+
+func testPolicyUnused() {
+	It("should report a policy selecting no endpoints", func() {
+		result := runViewerSafe(Default, nil, "policy", "unused", "-o=json")
+		entries := jqSafe(Default, result, "-r", `.[] | select(.namespace == "test-l4" and .name == "l4-unused") | [.kind, .reason, .endpoints] | @csv`)
+		entriesString := strings.TrimSpace(strings.Replace(string(entries), `"`, "", -1))
+		Expect(entriesString).To(Equal("CiliumNetworkPolicy,no endpoints,0"))
+	})
+}
+
+func testPolicyImpact() {
+	It("should find entries generated only by the policy", func() {
+		result := runViewerSafe(Default, nil, "policy", "impact", "-o=json", "cnp/test-l4/l4-ingress-explicit-allow-tcp")
+		removed := jqSafe(Default, result, "-r", `[.endpoints > 0, .removed_entries > 0] | @csv`)
+		Expect(strings.TrimSpace(string(removed))).To(Equal("true,true"))
+	})
+
+	It("should keep entries also generated by another policy", func() {
+		result := runViewerSafe(Default, nil, "policy", "impact", "-o=json", "netpol/test-l4/l4-ingress-all-allow-tcp-native")
+		removed := jqSafe(Default, result, "-r", `[.endpoints > 0, .removed_entries, (.connections | length)] | @csv`)
+		Expect(strings.TrimSpace(string(removed))).To(Equal("true,0,0"))
+	})
+}
@@ -174,3 +174,240 @@ Sender,Egress,Deny,cidr:8.8.4.4/32,false,false,132,53`
 		Expect(resultString).To(Equal(expectedEgressPrivate), "compare failed. actual: %s\nexpected: %s", resultString, expectedEgressPrivate)
 	})
 }
+
+func testReachVerdict() {
+	cases := []struct {
+		Namespace string
+		Selector  string
+		Protocol  string
+		Port      string
+		Expected  string
+	}{
+		{
+			Namespace: "test-l3",
+			Selector:  "test=l3-ingress-explicit-allow-all",
+			Protocol:  "tcp",
+			Port:      "80",
+			Expected: `Sender,ALLOWED
+Receiver,ALLOWED
+Overall,ALLOWED`,
+		},
+		{
+			Namespace: "test-l3",
+			Selector:  "test=l3-ingress-explicit-deny-all",
+			Protocol:  "tcp",
+			Port:      "80",
+			Expected: `Sender,ALLOWED
+Receiver,DENIED
+Overall,DENIED`,
+		},
+		{
+			Namespace: "test-l4",
+			Selector:  "test=l4-ingress-explicit-allow-tcp",
+			Protocol:  "tcp",
+			Port:      "8000",
+			Expected: `Sender,ALLOWED
+Receiver,ALLOWED
+Overall,ALLOWED`,
+		},
+		{
+			Namespace: "test-l4",
+			Selector:  "test=l4-ingress-explicit-allow-tcp",
+			Protocol:  "udp",
+			Port:      "8000",
+			Expected: `Sender,DENIED
+Receiver,DENIED
+Overall,DENIED`,
+		},
+		{
+			Namespace: "test-l4",
+			Selector:  "test=l4-egress-explicit-deny-tcp",
+			Protocol:  "tcp",
+			Port:      "8000",
+			Expected: `Sender,DENIED
+Receiver,ALLOWED
+Overall,DENIED`,
+		},
+	}
+
+	It("should compute the verdict", func() {
+		for _, c := range cases {
+			By(fmt.Sprintf("checking %v %v %v/%v", c.Namespace, c.Selector, c.Protocol, c.Port))
+			fromOption := "--from=test/" + onePodByLabelSelector(Default, "test", "test=self")
+			toOption := fmt.Sprintf("--to=%s/%s", c.Namespace, onePodByLabelSelector(Default, c.Namespace, c.Selector))
+
+			result := runViewerSafe(Default, nil, "reach", "-o=json", fromOption, toOption, "--protocol="+c.Protocol, "--port="+c.Port)
+			result = jqSafe(Default, result, "-r", `(.sides[] | [.role, .verdict]), ["Overall", .verdict] | @csv`)
+			resultString := strings.TrimSpace(strings.Replace(string(result), `"`, "", -1))
+			Expect(resultString).To(Equal(c.Expected), "compare failed. selector: %s\nactual: %s\nexpected: %s", c.Selector, resultString, c.Expected)
+
+			_, _, err := runViewer(nil, "reach", fromOption, toOption, "--protocol="+c.Protocol, "--port="+c.Port, "--check")
+			if strings.HasSuffix(c.Expected, "ALLOWED") {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		}
+	})
+}
+
+func testReachPeers() {
+	It("should list peers which can reach the pod", func() {
+		toOption := "--to=test-l4/" + onePodByLabelSelector(Default, "test-l4", "test=l4-ingress-explicit-allow-tcp")
+		result := runViewerSafe(Default, nil, "reach", "-o=json", toOption)
+		result = jqSafe(Default, result, "-r", `.[] | select(.namespace == "test") | [.role, .direction, .workload, .endpoint_count, .protocol, .port, .peer_verdict, .verdict] | @csv`)
+		resultString := strings.TrimSpace(strings.Replace(string(result), `"`, "", -1))
+		expected := "Receiver,Ingress,deploy/self,2,6,8000,ALLOWED,ALLOWED"
+		Expect(resultString).To(Equal(expected), "compare failed. actual: %s\nexpected: %s", resultString, expected)
+	})
+
+	It("should list peers which the pod can reach", func() {
+		fromOption := "--from=test/" + onePodByLabelSelector(Default, "test", "test=self")
+		result := runViewerSafe(Default, nil, "reach", "-o=json", fromOption, "--protocol=tcp", "--port=8000")
+		workloads := jqSafe(Default, result, "-r", `[.[] | select(.verdict == "ALLOWED") | .workload] | sort | .[]`)
+		Expect(strings.Fields(string(workloads))).To(ContainElement("deploy/l4-ingress-explicit-allow-tcp"))
+		Expect(strings.Fields(string(workloads))).NotTo(ContainElement("deploy/l4-egress-explicit-deny-tcp"))
+	})
+
+	It("should label the wildcard peer as any identity", func() {
+		toOption := "--to=test-l4/" + onePodByLabelSelector(Default, "test-l4", "test=l4-ingress-all-allow-tcp")
+		result := runViewerSafe(Default, nil, "reach", "-o=json", toOption)
+		result = jqSafe(Default, result, "-r", `.[] | select(.identity == 0) | [.workload, .protocol, .port, .peer_verdict, .verdict] | @csv`)
+		resultString := strings.TrimSpace(strings.Replace(string(result), `"`, "", -1))
+		expected := "any identity,6,8000,-,UNKNOWN"
+		Expect(resultString).To(Equal(expected), "compare failed. actual: %s\nexpected: %s", resultString, expected)
+	})
+}
+
+func testReachMatrix() {
+	selector := "-N=kubernetes.io/metadata.name in (test,test-l4)"
+
+	It("should compute reachability between namespaces", func() {
+		result := runViewerSafe(Default, nil, "reach", "matrix", "-o=json", selector)
+		resultString := string(jqSafe(Default, result, "-r", `.[] | select(.from == "test" and .to == "test-l4") | .allowed`))
+		Expect(strings.TrimSpace(resultString)).To(Equal("true"))
+
+		ports := jqSafe(Default, result, "-r", `.[] | select(.from == "test" and .to == "test-l4") | .ports[]`)
+		Expect(strings.Fields(string(ports))).To(ContainElement("TCP/8000"))
+	})
+
+	It("should write the matrix in CSV and HTML", func() {
+		result := runViewerSafe(Default, nil, "reach", "matrix", "-o=csv", selector)
+		lines := strings.Split(strings.TrimSpace(string(result)), "\n")
+		Expect(lines[0]).To(Equal("from,to,allowed,ports,bytes"))
+		Expect(lines).To(HaveLen(5))
+
+		result = runViewerSafe(Default, nil, "reach", "matrix", "-o=html", selector)
+		Expect(string(result)).To(ContainSubstring("<table>"))
+		Expect(string(result)).To(ContainSubstring(`<th class="to">test-l4</th>`))
+	})
+
+	It("should exclude denied ports from an allowed range", func() {
+		result := runViewerSafe(Default, nil, "reach", "matrix", "-o=json", "-n=test-matrix", "--by=workload")
+		cell := `.[] | select(.from == "test-matrix/deploy/matrix" and .to == "test-matrix/deploy/matrix")`
+		allowed := jqSafe(Default, result, "-r", cell+` | .allowed`)
+		Expect(strings.TrimSpace(string(allowed))).To(Equal("true"))
+
+		ports := strings.Fields(string(jqSafe(Default, result, "-r", cell+` | .ports[]`)))
+		Expect(ports).NotTo(ContainElement("ANY"))
+		Expect(ports).NotTo(ContainElement("TCP/ANY"))
+		Expect(ports).To(ContainElements("UDP/ANY", "TCP/7936-7999", "TCP/8001"))
+	})
+}
+
+func testReachService() {
+	It("should compute the verdict for every backend of a service", func() {
+		fromOption := "--from=test/" + onePodByLabelSelector(Default, "test", "test=self")
+		backend := "test-l4/" + onePodByLabelSelector(Default, "test-l4", "test=l4-ingress-explicit-allow-tcp")
+
+		result := runViewerSafe(Default, nil, "reach", "-o=json", fromOption, "--to=svc/test-l4/l4-ingress-explicit-allow-tcp")
+		backends := jqSafe(Default, result, "-r", `.backends[] | [.service_port, .backend, .target_port, .verdict] | @csv`)
+		backendsString := strings.TrimSpace(strings.Replace(string(backends), `"`, "", -1))
+		expected := fmt.Sprintf("http,%s,8000,ALLOWED\nalt,%s,8080,DENIED", backend, backend)
+		Expect(backendsString).To(Equal(expected))
+
+		ports := jqSafe(Default, result, "-r", `.ports[] | [.service_port, .port, .node_port > 0, .verdict] | @csv`)
+		portsString := strings.TrimSpace(strings.Replace(string(ports), `"`, "", -1))
+		Expect(portsString).To(Equal("http,80,true,ALLOWED\nalt,8080,true,DENIED"))
+
+		// Select a service port by name
+		_, _, err := runViewer(nil, "reach", fromOption, "--to=svc/test-l4/l4-ingress-explicit-allow-tcp:http", "--check")
+		Expect(err).NotTo(HaveOccurred())
+		_, _, err = runViewer(nil, "reach", fromOption, "--to=svc/test-l4/l4-ingress-explicit-allow-tcp:8080", "--check")
+		Expect(err).To(HaveOccurred())
+	})
+}
+
+func testReachEntity() {
+	It("should compute the verdict for entities", func() {
+		self := "test/" + onePodByLabelSelector(Default, "test", "test=self")
+
+		result := runViewerSafe(Default, nil, "reach", "-o=json", "--from="+self, "--to-entity=kube-apiserver", "--port=443")
+		resultString := strings.TrimSpace(string(jqSafe(Default, result, "-r", `[.peers[].peer, .verdict] | @csv`)))
+		Expect(resultString).To(Equal(`"reserved:kube-apiserver","DENIED"`))
+
+		_, _, err := runViewer(nil, "reach", "--from="+self, "--to-entity=kube-apiserver", "--port=443", "--check")
+		Expect(err).To(HaveOccurred())
+
+		// Traffic from the local host is allowed by default
+		result = runViewerSafe(Default, nil, "reach", "-o=json", "--from-entity=host", "--to="+self, "--port=8000")
+		resultString = strings.TrimSpace(string(jqSafe(Default, result, "-r", `[.peers[].side.role, .verdict] | @csv`)))
+		Expect(resultString).To(Equal(`"Receiver","ALLOWED"`))
+	})
+
+	It("should look up FQDNs in the DNS cache", func() {
+		self := "test/" + onePodByLabelSelector(Default, "test", "test=self")
+		stdout, stderr, err := runViewer(nil, "reach", "--from="+self, "--to-fqdn=unknown.example.com")
+		Expect(err).To(HaveOccurred())
+		Expect(string(stdout) + string(stderr)).To(ContainSubstring("not found in the DNS cache"))
+	})
+
+	It("should compute the verdict for a toFQDNs peer", func() {
+		fqdn := onePodByLabelSelector(Default, "test-fqdn", "test=fqdn")
+		name := "kubernetes.default.svc.cluster.local"
+
+		// Resolve the name through the DNS proxy to fill the cache on the node
+		Eventually(func(g Gomega) {
+			kubectlSafe(g, nil, "exec", "-n=test-fqdn", fqdn, "--", "getent", "hosts", name)
+			result := runViewerSafe(g, nil, "reach", "-o=json", "--from=test-fqdn/"+fqdn, "--to-fqdn="+name, "--port=443")
+			peers := jqSafe(g, result, "-r", `.peers[].peer`)
+			g.Expect(strings.Fields(string(peers))).NotTo(BeEmpty())
+			for _, p := range strings.Fields(string(peers)) {
+				g.Expect(p).To(HavePrefix("fqdn:" + name + "("))
+			}
+			verdict := jqSafe(g, result, "-r", `.verdict`)
+			g.Expect(strings.TrimSpace(string(verdict))).To(Equal("ALLOWED"))
+		}).Should(Succeed())
+
+		result := runViewerSafe(Default, nil, "reach", "-o=json", "--from=test-fqdn/"+fqdn, "--to-fqdn="+name, "--port=80")
+		verdict := jqSafe(Default, result, "-r", `.verdict`)
+		Expect(strings.TrimSpace(string(verdict))).To(Equal("DENIED"))
+
+		result = runViewerSafe(Default, nil, "inspect", "-o=json", "-n=test-fqdn", fqdn, "--egress")
+		examples := jqSafe(Default, result, "-r", `.[] | select(.port == 443) | .example_endpoint`)
+		Expect(strings.Fields(string(examples))).To(ContainElement("fqdn:" + name))
+	})
+}
+
+func testReachSuggest() {
+	It("should suggest a policy which allows the traffic", func() {
+		fromOption := "--from=test/" + onePodByLabelSelector(Default, "test", "test=self")
+		toOption := "--to=test-l4/" + onePodByLabelSelector(Default, "test-l4", "test=l4-ingress-explicit-allow-tcp")
+		result := runViewerSafe(Default, nil, "reach", fromOption, toOption, "--protocol=udp", "--port=8000", "--suggest")
+		resultString := string(result)
+		Expect(resultString).To(ContainSubstring("kind: CiliumNetworkPolicy"))
+		Expect(resultString).To(ContainSubstring("egress:"))
+		Expect(resultString).To(ContainSubstring("ingress:"))
+		Expect(resultString).To(ContainSubstring(`port: "8000"`))
+		Expect(resultString).To(ContainSubstring("protocol: UDP"))
+	})
+
+	It("should name the deny policy", func() {
+		fromOption := "--from=test/" + onePodByLabelSelector(Default, "test", "test=self")
+		toOption := "--to=test-l3/" + onePodByLabelSelector(Default, "test-l3", "test=l3-ingress-explicit-deny-all")
+		result := runViewerSafe(Default, nil, "reach", fromOption, toOption, "--protocol=tcp", "--port=80", "--suggest")
+		resultString := string(result)
+		Expect(resultString).To(ContainSubstring("denied explicitly by CiliumNetworkPolicy test-l3/l3-ingress-explicit-deny-all"))
+		Expect(resultString).NotTo(ContainSubstring("kind: CiliumNetworkPolicy"))
+	})
+}
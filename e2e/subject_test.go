@@ -30,8 +30,10 @@ default
 kube-system
 local-path-storage
 test
+test-fqdn
 test-l3
-test-l4`,
+test-l4
+test-matrix`,
 		},
 		// npv subject --group=pod should display subjects as POD when a single namespace is selected
 		{
@@ -43,6 +45,26 @@ test-l4`,
 			Args:     []string{"-A", "-l=test=self", "--group=pod"},
 			Expected: fmt.Sprintf("test/%s\ntest/%s", selfNames[0], selfNames[1]),
 		},
+		// npv subject --group=label=KEY should display subjects as label values
+		{
+			Args:     []string{"-N=group=test", "--group=label=group"},
+			Expected: `test`,
+		},
+		{
+			Args: []string{"-n=test-l4", "--group=label=test"},
+			Expected: `l4-egress-explicit-deny-any
+l4-egress-explicit-deny-tcp
+l4-ingress-all-allow-tcp
+l4-ingress-explicit-allow-any
+l4-ingress-explicit-allow-tcp
+l4-ingress-explicit-deny-any
+l4-ingress-explicit-deny-udp`,
+		},
+		// npv subject --group=label=KEY should display (none) for pods without the label
+		{
+			Args:     []string{"-n=test-l3", "--group=label=nonexistent"},
+			Expected: `(none)`,
+		},
 		// Test selector options //
 		// npv subject -A should select subjects from all namespaces (already tested)
 		// npv subject -N should select subjects from the selected namespaces
@@ -69,6 +91,35 @@ test-l4`,
 		}
 	})
 
+	It("should group subjects by identity", func() {
+		id := string(kubectlSafe(Default, nil, "get", "cep", "-n=test", selfNames[0], "-o=jsonpath={.status.identity.id}"))
+
+		result := strings.TrimSpace(string(runViewerSafe(Default, nil, "subject", "-n=test", "-l=test=self", "--group=identity")))
+		Expect(result).To(Equal(id))
+	})
+
+	It("should sample one pod per identity", func() {
+		result := runViewerSafe(Default, nil, "subject", "-n=test", "-l=test=self", "--sample=cluster", "-o=json")
+		representative := string(jqSafe(Default, result, "-r", ".[0].representative"))
+		members := string(jqSafe(Default, result, "-r", ".[0].members | join(\",\")"))
+
+		Expect(string(jqSafe(Default, result, "-r", "length"))).To(Equal("1"))
+		Expect(representative).To(Equal("test/" + selfNames[0]))
+		Expect(members).To(Equal("test/" + selfNames[1]))
+	})
+
+	It("should report skipped pods", func() {
+		_, stderr, err := runViewer(nil, "subject", "-n=kube-system", "--show-skipped", "-o=json")
+		Expect(err).NotTo(HaveOccurred())
+
+		// cilium-agent pods are host-networked
+		agents := string(kubectlSafe(Default, nil, "get", "pod", "-n=kube-system", "-l=k8s-app=cilium", "-o=jsonpath={.items[*].metadata.name}"))
+		for _, agent := range strings.Fields(agents) {
+			reason := string(jqSafe(Default, stderr, "-r", fmt.Sprintf(`.skipped[] | select(.name == "%s") | .reason`, agent)))
+			Expect(reason).To(Equal("hostNetwork"))
+		}
+	})
+
 	It("should handle --node", func() {
 		expected := strings.TrimSpace(string(kubectlSafe(Default, nil, "get", "pod", "-n=cilium-agent-proxy", "--field-selector=spec.nodeName=kind-worker", "-oname")))
 		expected = strings.ReplaceAll(expected, "pod/", "cilium-agent-proxy/")
@@ -54,13 +54,32 @@ func runTest() {
 	Context("list", testList)
 	Context("list-with-selector", testListWithSelector)
 	Context("list-manifests", testListManifests)
+	Context("list-rules", testListRules)
 	Context("id-tree", testIdTree)
 	Context("inspect", testInspect)
+	Context("host", testHost)
 	Context("summary", testSummary)
 	Context("summary-all", testSummaryAll)
 	Context("summary-node", testSummaryNode)
+	Context("coverage", testCoverage)
+	Context("exposure", testExposure)
+	Context("capacity", testCapacity)
+	Context("health-endpoints", testHealthEndpoints)
+	Context("top-drops", testTopDrops)
+	Context("watch", testWatch)
 	Context("manifest-generate", testManifestGenerate)
 	Context("manifest-range", testManifestRange)
 	Context("reach", testReach)
 	Context("reach-cidr", testReachCIDR)
+	Context("reach-verdict", testReachVerdict)
+	Context("reach-peers", testReachPeers)
+	Context("reach-matrix", testReachMatrix)
+	Context("reach-service", testReachService)
+	Context("reach-entity", testReachEntity)
+	Context("reach-suggest", testReachSuggest)
+	Context("blast-radius", testBlastRadius)
+	Context("policy-show", testPolicyShow)
+	Context("policy-unused", testPolicyUnused)
+	Context("policy-impact", testPolicyImpact)
+	Context("verify-consistency", testVerifyConsistency)
 }
@@ -0,0 +1,70 @@
+package e2e
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sendDeniedTraffic sends DNS queries to 8.8.4.4, which is denied for self pods.
+// The queries are expected to time out, so the error is ignored.
+func sendDeniedTraffic(podName string) {
+	kubectl(nil, "exec", "-n=test", podName, "--", "dig", "@8.8.4.4", "+tries=1", "+time=1", "google.com")
+}
+
+func testTopDrops() {
+	It("should rank only denied traffic", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+		sendDeniedTraffic(podName)
+
+		result := runViewerSafe(Default, nil, "top", "drops", "-o=json", "-N=group=test", "--limit=0")
+		count := jqSafe(Default, result, "-r", `length`)
+		Expect(string(count)).NotTo(Equal("0"))
+		valid := jqSafe(Default, result, "-r", `all(.[]; .packets > 0 and .namespace != null and .direction != null)`)
+		Expect(string(valid)).To(Equal("true"))
+		found := jqSafe(Default, result, "-r", `any(.[]; .namespace == "test" and .name == "`+podName+`" and .direction == "Egress" and .peer == "cidr:8.8.4.4/32" and .port == "UDP/53")`)
+		Expect(string(found)).To(Equal("true"))
+	})
+
+	It("should aggregate by subject", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+		sendDeniedTraffic(podName)
+
+		result := runViewerSafe(Default, nil, "top", "drops", "-o=json", "-N=group=test", "--by=subject", "--limit=0")
+		count := jqSafe(Default, result, "-r", `length`)
+		Expect(string(count)).NotTo(Equal("0"))
+		valid := jqSafe(Default, result, "-r", `all(.[]; .name != null and .peer == null and .port == null)`)
+		Expect(string(valid)).To(Equal("true"))
+		found := jqSafe(Default, result, "-r", `any(.[]; .namespace == "test" and .name == "`+podName+`")`)
+		Expect(string(found)).To(Equal("true"))
+	})
+
+	It("should show rates with --interval", func() {
+		podName := onePodByLabelSelector(Default, "test", "test=self")
+
+		// send the traffic while the viewer waits for the interval
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 3 {
+				time.Sleep(2 * time.Second)
+				sendDeniedTraffic(podName)
+			}
+		}()
+		result := runViewerSafe(Default, nil, "top", "drops", "-o=json", "-N=group=test", "--interval=10s", "--limit=0")
+		<-done
+
+		count := jqSafe(Default, result, "-r", `length`)
+		Expect(string(count)).NotTo(Equal("0"))
+		valid := jqSafe(Default, result, "-r", `all(.[]; .packets_per_second > 0)`)
+		Expect(string(valid)).To(Equal("true"))
+		found := jqSafe(Default, result, "-r", `any(.[]; .name == "`+podName+`" and .peer == "cidr:8.8.4.4/32" and .port == "UDP/53")`)
+		Expect(string(found)).To(Equal("true"))
+	})
+
+	It("should reject an unknown key", func() {
+		_, _, err := runViewer(nil, "top", "drops", "--by=node")
+		Expect(err).To(HaveOccurred())
+	})
+}
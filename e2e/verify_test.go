@@ -0,0 +1,42 @@
+package e2e
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testVerifyConsistency() {
+	cases := []struct {
+		Namespace string
+		Selector  string
+	}{
+		{
+			Namespace: "test",
+			Selector:  "test=self",
+		},
+		{
+			Namespace: "test-l3",
+			Selector:  "test=l3-ingress-explicit-allow-all",
+		},
+	}
+
+	It("should find no inconsistency among replicas", func() {
+		for _, c := range cases {
+			result := runViewerSafe(Default, nil, "verify", "consistency", "-o=json", "-n="+c.Namespace, "-l="+c.Selector)
+
+			entries := jqSafe(Default, result, "-r", `.entries | length`)
+			Expect(strings.TrimSpace(string(entries))).To(Equal("0"), "namespace: %s, selector: %s", c.Namespace, c.Selector)
+
+			// The replicas share a single identity, see Makefile
+			identities := jqSafe(Default, result, "-r", `.identities[] | [.pods, .inconsistent] | @csv`)
+			Expect(strings.TrimSpace(string(identities))).To(Equal("2,0"), "namespace: %s, selector: %s", c.Namespace, c.Selector)
+		}
+	})
+
+	It("should reject sampling across the cluster", func() {
+		_, _, err := runViewer(nil, "verify", "consistency", "-n=test", "--sample=cluster")
+		Expect(err).To(HaveOccurred())
+	})
+}
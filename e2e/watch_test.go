@@ -0,0 +1,14 @@
+package e2e
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testWatch() {
+	It("should require a terminal", func() {
+		_, stderr, err := runViewer(nil, "watch", "-n=test")
+		Expect(err).To(HaveOccurred())
+		Expect(string(stderr)).To(ContainSubstring("watch requires a terminal"))
+	})
+}
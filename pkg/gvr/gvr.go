@@ -31,3 +31,21 @@ var CIDRGroup schema.GroupVersionResource = schema.GroupVersionResource{
 	Version:  "v2alpha1",
 	Resource: "ciliumcidrgroups",
 }
+
+var K8sNetworkPolicy schema.GroupVersionResource = schema.GroupVersionResource{
+	Group:    "networking.k8s.io",
+	Version:  "v1",
+	Resource: "networkpolicies",
+}
+
+var AdminNetworkPolicy schema.GroupVersionResource = schema.GroupVersionResource{
+	Group:    "policy.networking.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "adminnetworkpolicies",
+}
+
+var BaselineAdminNetworkPolicy schema.GroupVersionResource = schema.GroupVersionResource{
+	Group:    "policy.networking.k8s.io",
+	Version:  "v1alpha1",
+	Resource: "baselineadminnetworkpolicies",
+}
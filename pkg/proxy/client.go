@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/netip"
+	"net/url"
 	"runtime/debug"
 	"slices"
 	"strconv"
@@ -46,6 +48,7 @@ type Client struct {
 	prefixIdentities []netip.Prefix
 	identityPrefixes map[uint32][]netip.Prefix
 	identityCIDRSets map[uint32]cidr.Set
+	fqdnIdentities   map[uint32][]string
 }
 
 var (
@@ -86,12 +89,7 @@ func getPodNodeName(ctx context.Context, c *kubernetes.Clientset, namespace, nam
 	return pod.Spec.NodeName, nil
 }
 
-func getProxyEndpoint(ctx context.Context, c *kubernetes.Clientset, namespace, name string) (string, error) {
-	targetNode, err := getPodNodeName(ctx, c, namespace, name)
-	if err != nil {
-		return "", err
-	}
-
+func getProxyEndpoint(ctx context.Context, c *kubernetes.Clientset, targetNode string) (string, error) {
 	pods, err := c.CoreV1().Pods(config.Namespace).List(ctx, metav1.ListOptions{
 		FieldSelector: "spec.nodeName=" + targetNode,
 		LabelSelector: config.Selector,
@@ -108,6 +106,24 @@ func getProxyEndpoint(ctx context.Context, c *kubernetes.Clientset, namespace, n
 	return fmt.Sprintf("http://%s:%d", podIP, config.Port), nil
 }
 
+// ListProxyNodes returns the set of nodes where cilium-agent-proxy is running.
+func ListProxyNodes(ctx context.Context, c *kubernetes.Clientset) (map[string]bool, error) {
+	pods, err := c.CoreV1().Pods(config.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: config.Selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]bool)
+	for _, p := range pods.Items {
+		if p.Status.PodIP != "" {
+			ret[p.Spec.NodeName] = true
+		}
+	}
+	return ret, nil
+}
+
 func getPodEndpointID(ctx context.Context, d *dynamic.DynamicClient, namespace, name string) (int64, error) {
 	ep, err := d.Resource(gvr.Endpoint).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
@@ -159,6 +175,15 @@ func fetchCIDRGroupsLocked(ctx context.Context, d *dynamic.DynamicClient) error
 }
 
 func CreateCiliumClient(ctx context.Context, stderr io.Writer, c *kubernetes.Clientset, d *dynamic.DynamicClient, namespace, name string) (*Client, error) {
+	targetNode, err := getPodNodeName(ctx, c, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return CreateCiliumClientForNode(ctx, stderr, c, d, targetNode)
+}
+
+// CreateCiliumClientForNode creates a client for the cilium-agent running on the specified node.
+func CreateCiliumClientForNode(ctx context.Context, stderr io.Writer, c *kubernetes.Clientset, d *dynamic.DynamicClient, targetNode string) (*Client, error) {
 	proxyMutex.Lock()
 	defer proxyMutex.Unlock()
 
@@ -166,12 +191,7 @@ func CreateCiliumClient(ctx context.Context, stderr io.Writer, c *kubernetes.Cli
 		return nil, err
 	}
 
-	targetNode, err := getPodNodeName(ctx, c, namespace, name)
-	if err != nil {
-		return nil, err
-	}
-
-	endpoint, err := getProxyEndpoint(ctx, c, namespace, name)
+	endpoint, err := getProxyEndpoint(ctx, c, targetNode)
 	if err != nil {
 		return nil, err
 	}
@@ -231,19 +251,12 @@ func (c *Client) testAgentVersion(ctx context.Context, stderr io.Writer) error {
 		panic("internal error; stderr is not specified")
 	}
 
-	data, err := c.queryProxy(ctx, "/version")
+	version, err := c.GetAgentVersion(ctx)
 	if err != nil {
 		return err
 	}
 
-	var result struct {
-		Cilium string `json:"cilium,omitempty"`
-	}
-	if err := json.Unmarshal(data, &result); err != nil {
-		return err
-	}
-
-	agentVersion := semver.MajorMinor(result.Cilium)
+	agentVersion := semver.MajorMinor(version)
 	moduleVersion := semver.MajorMinor(ciliumModuleVersion)
 	if agentVersion != moduleVersion {
 		fmt.Fprintf(stderr, "Warning: %s is running Cilium %s, but npv is built for %s. Result may be incorrect.\n", c.node, agentVersion, moduleVersion)
@@ -252,6 +265,22 @@ func (c *Client) testAgentVersion(ctx context.Context, stderr io.Writer) error {
 	return nil
 }
 
+// GetAgentVersion returns the Cilium version of the agent on the client's node.
+func (c *Client) GetAgentVersion(ctx context.Context) (string, error) {
+	data, err := c.queryProxy(ctx, "/version")
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Cilium string `json:"cilium,omitempty"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal /version: %w", err)
+	}
+	return result.Cilium, nil
+}
+
 func (c *Client) DumpEndpoint(ctx context.Context, namespace, name string) ([]byte, error) {
 	endpointID, err := getPodEndpointID(ctx, c.dynamicClient, namespace, name)
 	if err != nil {
@@ -261,12 +290,110 @@ func (c *Client) DumpEndpoint(ctx context.Context, namespace, name string) ([]by
 	return c.queryProxy(ctx, fmt.Sprintf("/v1/endpoint/%d", endpointID))
 }
 
+// GetHostEndpointID returns the ID of the host endpoint on the client's node.
+func (c *Client) GetHostEndpointID(ctx context.Context) (int64, error) {
+	data, err := c.queryProxy(ctx, "/host-endpoint")
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal /host-endpoint: %w", err)
+	}
+	return result.ID, nil
+}
+
+// FQDNIdentity is an IP address of an FQDN found in the DNS proxy cache, and its security identity.
+type FQDNIdentity struct {
+	FQDN     string `json:"fqdn"`
+	IP       string `json:"ip"`
+	Identity uint32 `json:"identity"`
+}
+
+// GetFQDNIdentities looks up an FQDN in the DNS proxy cache on the client's node.
+// The name may contain wildcards as in "cilium fqdn cache list --matchpattern".
+func (c *Client) GetFQDNIdentities(ctx context.Context, name string) ([]FQDNIdentity, error) {
+	data, err := c.queryProxy(ctx, "/fqdn/"+url.PathEscape(name))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []FQDNIdentity
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal /fqdn: %w", err)
+	}
+	return result, nil
+}
+
+// GetPolicyMapMax returns the maximum number of entries in a policy map, configured by bpf-policy-map-max on the client's node.
+func (c *Client) GetPolicyMapMax(ctx context.Context) (int, error) {
+	data, err := c.queryProxy(ctx, "/policy-map-max")
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		PolicyMapMax int `json:"policy_map_max"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal /policy-map-max: %w", err)
+	}
+	if result.PolicyMapMax <= 0 {
+		return 0, fmt.Errorf("invalid policy map size: %d", result.PolicyMapMax)
+	}
+	return result.PolicyMapMax, nil
+}
+
+// GetPolicyRevision returns the current policy revision of the agent on the client's node.
+func (c *Client) GetPolicyRevision(ctx context.Context) (int64, error) {
+	data, err := c.queryProxy(ctx, "/policy-revision")
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Revision int64 `json:"revision"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal /policy-revision: %w", err)
+	}
+	return result.Revision, nil
+}
+
+// GetSelectorIdentities returns the identities currently matched by each selector in the selector cache on the client's node.
+func (c *Client) GetSelectorIdentities(ctx context.Context) (map[string][]uint32, error) {
+	data, err := c.queryProxy(ctx, "/selectors")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		Selector   string   `json:"selector"`
+		Identities []uint32 `json:"identities"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal /selectors: %w", err)
+	}
+
+	ret := make(map[string][]uint32, len(result))
+	for _, r := range result {
+		ret[r.Selector] = r.Identities
+	}
+	return ret, nil
+}
+
 func (c *Client) GetEndpointResponse(ctx context.Context, namespace, name string) (*endpoint.GetEndpointIDOK, error) {
 	endpointID, err := getPodEndpointID(ctx, c.dynamicClient, namespace, name)
 	if err != nil {
 		return nil, err
 	}
+	return c.GetEndpointResponseByID(ctx, endpointID)
+}
 
+func (c *Client) GetEndpointResponseByID(ctx context.Context, endpointID int64) (*endpoint.GetEndpointIDOK, error) {
 	params := endpoint.GetEndpointIDParams{
 		Context: ctx,
 		ID:      strconv.FormatInt(endpointID, 10),
@@ -307,6 +434,10 @@ func (c *Client) prepareCIDRs(ctx context.Context) error {
 	//   labels:
 	//     - cidr:1.1.1.1/32
 	//     - reserved:world
+	// - id: 16777221
+	//   labels:
+	//     - fqdn:*.example.com
+	//     - reserved:world
 
 	var m []models.Identity
 	if err := json.Unmarshal(data, &m); err != nil {
@@ -315,6 +446,7 @@ func (c *Client) prepareCIDRs(ctx context.Context) error {
 
 	ip := make(map[uint32][]netip.Prefix)
 	pi := make([]netip.Prefix, 0)
+	fi := make(map[uint32][]string)
 
 OUTER:
 	for _, id := range m {
@@ -341,6 +473,13 @@ OUTER:
 		}
 
 		cidrModel := lbls.GetFromSource(labels.LabelSourceCIDR).GetPrintableModel()
+		// IPs resolved by DNS are labeled with the matching FQDN selectors, possibly without their CIDRs
+		if fqdnModel := lbls.GetFromSource(labels.LabelSourceFQDN); len(fqdnModel) > 0 {
+			fi[uint32(id.ID)] = slices.Sorted(maps.Keys(fqdnModel))
+			if len(cidrModel) == 0 {
+				continue
+			}
+		}
 		if len(cidrModel) != 1 {
 			return fmt.Errorf("unexpected CIDR label for identity %d", id.ID)
 		}
@@ -361,6 +500,7 @@ OUTER:
 	})
 	c.identityPrefixes = ip
 	c.prefixIdentities = pi
+	c.fqdnIdentities = fi
 	return nil
 }
 
@@ -409,12 +549,24 @@ func (c *Client) GetCIDRForIdentity(ctx context.Context, id uint32) (*cidr.Set,
 	return &ret, nil
 }
 
+// GetFQDNSelectorsForIdentity returns the FQDN selectors of a node-local identity allocated for IPs resolved by toFQDNs rules.
+// It returns nil for other identities. Such identities depend on DNS responses seen by each node, and may have no CIDRs.
+func (c *Client) GetFQDNSelectorsForIdentity(ctx context.Context, id uint32) ([]string, error) {
+	if err := c.prepareCIDRs(ctx); err != nil {
+		return nil, err
+	}
+	return c.fqdnIdentities[id], nil
+}
+
 func (c *Client) QueryPolicyMap(ctx context.Context, namespace, name string) ([]PolicyEntry, error) {
 	endpointID, err := getPodEndpointID(ctx, c.dynamicClient, namespace, name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pod endpoint ID: %w", err)
 	}
+	return c.QueryPolicyMapByID(ctx, endpointID)
+}
 
+func (c *Client) QueryPolicyMapByID(ctx context.Context, endpointID int64) ([]PolicyEntry, error) {
 	data, err := c.queryProxy(ctx, fmt.Sprintf("/policy/%d", endpointID))
 	if err != nil {
 		return nil, err
@@ -78,6 +78,15 @@ func MakeCIDRFilter(ingress, egress bool, s cidr.Set) PolicyFilter {
 			return false, nil
 		}
 
+		// The IPs of an identity for toFQDNs rules are not known from its labels
+		selectors, err := client.GetFQDNSelectorsForIdentity(ctx, p.Key.Identity)
+		if err != nil {
+			return false, err
+		}
+		if len(selectors) > 0 {
+			return false, nil
+		}
+
 		// Retrieve identity information
 		idCIDR, err := client.GetCIDRForIdentity(ctx, p.Key.Identity)
 		if err != nil {
@@ -115,6 +124,19 @@ func MakeAllFilter(filters ...PolicyFilter) PolicyFilter {
 	}
 }
 
+func MakeAnyFilter(filters ...PolicyFilter) PolicyFilter {
+	// Unlike MakeAllFilter, nil filters are not allowed here because nil means "pass everything"
+	return func(ctx context.Context, client *Client, p *PolicyEntry) (bool, error) {
+		for _, f := range filters {
+			result, err := f(ctx, client, p)
+			if result || err != nil {
+				return result, err
+			}
+		}
+		return false, nil
+	}
+}
+
 func FilterPolicyMap(ctx context.Context, client *Client, policies []PolicyEntry, pred PolicyFilter) ([]PolicyEntry, error) {
 	if pred == nil {
 		return policies, nil
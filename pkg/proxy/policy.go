@@ -34,3 +34,22 @@ func (p PolicyEntry) IsWildcardProtocol() bool {
 func (p PolicyEntry) IsWildcardPort() bool {
 	return p.Key.GetDestPort() == 0
 }
+
+// SubtractPolicyMap returns the entries of the later sample with the counters increased since the earlier sample.
+// Counters restart from zero when an entry is recreated, e.g. on policy regeneration, so such an entry keeps its counters.
+func SubtractPolicyMap(before, after []PolicyEntry) []PolicyEntry {
+	prev := make(map[policymap.PolicyKey]PolicyEntry, len(before))
+	for _, p := range before {
+		prev[p.Key] = p
+	}
+
+	ret := make([]PolicyEntry, len(after))
+	for i, p := range after {
+		if b, ok := prev[p.Key]; ok && p.Packets >= b.Packets && p.Bytes >= b.Bytes {
+			p.Packets -= b.Packets
+			p.Bytes -= b.Bytes
+		}
+		ret[i] = p
+	}
+	return ret
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"github.com/cilium/cilium/pkg/maps/policymap"
+)
+
+// Verdict is the result of a policy map lookup for a single direction.
+type Verdict struct {
+	Allowed bool
+	// Decision holds the entries that decided the verdict.
+	// It is empty when the traffic is denied by default.
+	Decision []PolicyEntry
+}
+
+// matches reports whether the entry's protocol and port prefix cover the given packet.
+func (p PolicyEntry) matches(protocol uint8, port uint16) bool {
+	prefixLen := p.Key.Prefixlen - policymap.StaticPrefixBits
+	if prefixLen == 0 {
+		return true
+	}
+	if p.Key.Nexthdr != protocol {
+		return false
+	}
+	portPrefixLen := p.Key.GetPortPrefixLen()
+	if portPrefixLen == 0 {
+		return true
+	}
+	mask := ^uint16(0) << (policymap.DestPortBits - uint32(portPrefixLen))
+	return p.Key.GetDestPort()&mask == port&mask
+}
+
+// lookup emulates an LPM lookup on the policy map for an exact identity.
+func lookup(policies []PolicyEntry, egress bool, identity uint32, protocol uint8, port uint16) *PolicyEntry {
+	var ret *PolicyEntry
+	for i := range policies {
+		p := &policies[i]
+		if p.IsEgress() != egress || p.Key.Identity != identity {
+			continue
+		}
+		if !p.matches(protocol, port) {
+			continue
+		}
+		if ret == nil || ret.Key.Prefixlen < p.Key.Prefixlen {
+			ret = p
+		}
+	}
+	return ret
+}
+
+// ComputeVerdict decides whether a packet from/to the peer identity passes the policy map.
+// This follows the precedence rules of __policy_can_access() in the datapath:
+// https://github.com/cilium/cilium/blob/v1.17.16/bpf/lib/policy.h
+//
+// 1. A deny entry is selected, if any.
+// 2. Of the two allow entries, the one with longer prefix length is selected.
+// 3. Otherwise the entry with non-wildcard identity is selected.
+//
+// Proxy port priority is not considered because it does not change whether the packet is allowed.
+func ComputeVerdict(policies []PolicyEntry, egress bool, peer uint32, protocol uint8, port uint16) Verdict {
+	l3 := lookup(policies, egress, peer, protocol, port)
+	if l3 != nil && l3.IsDeny() {
+		return Verdict{Allowed: false, Decision: []PolicyEntry{*l3}}
+	}
+
+	l4 := lookup(policies, egress, 0, protocol, port)
+	if l4 != nil && l4.IsDeny() {
+		return Verdict{Allowed: false, Decision: []PolicyEntry{*l4}}
+	}
+
+	switch {
+	case l3 != nil && l4 != nil:
+		if l4.Key.Prefixlen > l3.Key.Prefixlen {
+			return Verdict{Allowed: true, Decision: []PolicyEntry{*l4}}
+		}
+		return Verdict{Allowed: true, Decision: []PolicyEntry{*l3}}
+	case l3 != nil:
+		return Verdict{Allowed: true, Decision: []PolicyEntry{*l3}}
+	case l4 != nil:
+		return Verdict{Allowed: true, Decision: []PolicyEntry{*l4}}
+	default:
+		return Verdict{Allowed: false}
+	}
+}
@@ -4,30 +4,72 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/cybozu-go/network-policy-viewer/pkg/gvr"
+	"github.com/cybozu-go/network-policy-viewer/pkg/proxy"
 )
 
 const (
 	GroupAll       = "all"
+	GroupIdentity  = "identity"
+	GroupLabel     = "label"
 	GroupNamespace = "namespace"
 	GroupPod       = "pod"
 )
 
+const (
+	SampleNone    = "none"
+	SampleNode    = "node"
+	SampleCluster = "cluster"
+)
+
 type SelectorConfig struct {
 	AllNamespaces     bool
 	NamespaceSelector string
 	Namespace         string
 	PodSelector       string
 	Node              string
+	Sample            string
+	IncludePending    bool
+}
+
+const (
+	SkipReasonHostNetwork = "hostNetwork"
+	SkipReasonNotRunning  = "not running"
+	SkipReasonNoEndpoint  = "no CiliumEndpoint"
+	SkipReasonNoIdentity  = "no identity"
+	SkipReasonNoProxy     = "proxy missing on the node"
+)
+
+// SkippedPod describes a pod excluded from the examination.
+type SkippedPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Node      string `json:"node"`
+	Reason    string `json:"reason"`
 }
 
 var (
 	group          string
+	groupLabelKey  string
 	selectorConfig *SelectorConfig
+
+	// podIdentities, podMembers, and skippedPods are filled by ListSubjectPods
+	podIdentities map[types.NamespacedName]uint32
+	podMembers    map[types.NamespacedName][]types.NamespacedName
+	skippedPods   []SkippedPod
 )
 
 func init() {
@@ -39,20 +81,44 @@ func GetGroup() string {
 }
 
 func SetGroup(g string) error {
+	if key, ok := strings.CutPrefix(g, "label="); ok {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("failed to parse --group: invalid label key %q: %s", key, strings.Join(errs, ", "))
+		}
+		group = GroupLabel
+		groupLabelKey = key
+		return nil
+	}
+
 	switch g {
 	case "a", "all":
 		g = GroupAll
+	case "i", "id", "identity", "identities":
+		g = GroupIdentity
 	case "n", "ns", "namespace", "namespaces":
 		g = GroupNamespace
 	case "p", "po", "pod", "pods", "":
 		g = GroupPod
 	default:
-		return fmt.Errorf("failed to parse --group: should be one of: all [a], ns [n], pod [p]")
+		return fmt.Errorf("failed to parse --group: should be one of: all [a], identity [i], label=KEY, ns [n], pod [p]")
 	}
 	group = g
 	return nil
 }
 
+func ParseSample(s string) (string, error) {
+	switch s {
+	case "", "none":
+		return SampleNone, nil
+	case "node":
+		return SampleNode, nil
+	case "cluster":
+		return SampleCluster, nil
+	default:
+		return "", fmt.Errorf("failed to parse --sample: should be one of: none, node, cluster")
+	}
+}
+
 func GetSelectorConfig() *SelectorConfig {
 	return selectorConfig
 }
@@ -99,6 +165,8 @@ func ShouldPrintSubject(podName string) bool {
 	switch group {
 	case GroupAll:
 		return false
+	case GroupIdentity, GroupLabel:
+		return podName == ""
 	case GroupNamespace:
 		return IsMultiNamespace()
 	case GroupPod:
@@ -108,41 +176,221 @@ func ShouldPrintSubject(podName string) bool {
 	}
 }
 
-func GetPodSubject(namespace, name string) string {
+func GetPodSubject(pod *corev1.Pod) string {
 	switch group {
 	case GroupAll:
 		return ""
+	case GroupIdentity:
+		id, ok := podIdentities[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]
+		if !ok {
+			return "-"
+		}
+		return strconv.FormatUint(uint64(id), 10)
+	case GroupLabel:
+		if v, ok := pod.Labels[groupLabelKey]; ok {
+			return v
+		}
+		return "(none)"
 	case GroupNamespace:
-		return namespace
+		return pod.Namespace
 	case GroupPod:
 		if IsMultiNamespace() {
-			return namespace + "/" + name
+			return pod.Namespace + "/" + pod.Name
 		} else {
-			return name
+			return pod.Name
 		}
 	default:
 		panic("internal error")
 	}
 }
 
+// GetPodMembers returns the pods represented by the given pod when sampling is enabled.
+// The result does not include the pod itself.
+func GetPodMembers(pod *corev1.Pod) []types.NamespacedName {
+	return podMembers[types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}]
+}
+
 // ListSubjectPods returns the pods that should be examined according to the current options.
-func ListSubjectPods(ctx context.Context, clientset *kubernetes.Clientset, name string) ([]*corev1.Pod, error) {
+// Pods that cannot be examined are recorded and can be retrieved with GetSkippedPods.
+func ListSubjectPods(ctx context.Context, clientset *kubernetes.Clientset, dynamicClient *dynamic.DynamicClient, name string) ([]*corev1.Pod, error) {
 	if (name != "") && (IsMultiNamespace() || selectorConfig.PodSelector != "") {
 		return nil, errors.New("multiple pods should not be selected when pod name is specified")
 	}
 
+	skippedPods = make([]SkippedPod, 0)
+
+	var pods []*corev1.Pod
 	if name != "" {
 		pod, err := clientset.CoreV1().Pods(selectorConfig.Namespace).Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
 			return nil, err
 		}
-		return []*corev1.Pod{pod}, nil
+		pods = filterPods([]*corev1.Pod{pod}, selectorConfig.IncludePending)
 	} else {
-		return ListCiliumManagedPods(ctx, clientset, GetNamespaceListOptions(), GetPodListOptions())
+		li, err := listPods(ctx, clientset, GetNamespaceListOptions(), GetPodListOptions())
+		if err != nil {
+			return nil, err
+		}
+		pods = filterPods(li, selectorConfig.IncludePending)
+	}
+
+	pods, err := filterPodsByEndpoint(ctx, dynamicClient, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err = filterPodsByProxy(ctx, clientset, pods)
+	if err != nil {
+		return nil, err
 	}
+
+	if selectorConfig.Sample == SampleNone {
+		return pods, nil
+	}
+	return samplePods(pods), nil
+}
+
+// GetSkippedPods returns the pods excluded by the last call of ListSubjectPods.
+func GetSkippedPods() []SkippedPod {
+	return skippedPods
+}
+
+func skipPod(p *corev1.Pod, reason string) {
+	skippedPods = append(skippedPods, SkippedPod{
+		Namespace: p.Namespace,
+		Name:      p.Name,
+		Node:      p.Spec.NodeName,
+		Reason:    reason,
+	})
+}
+
+func filterPods(pods []*corev1.Pod, includePending bool) []*corev1.Pod {
+	ret := make([]*corev1.Pod, 0, len(pods))
+	for _, p := range pods {
+		// Skip non-relevant pods
+		if p.Spec.HostNetwork {
+			skipPod(p, SkipReasonHostNetwork)
+			continue
+		}
+		switch {
+		case p.Status.Phase == corev1.PodRunning:
+		case p.Status.Phase == corev1.PodPending && includePending:
+			// Pending pods are examined only when their endpoints exist, which is checked later
+		default:
+			skipPod(p, SkipReasonNotRunning)
+			continue
+		}
+		ret = append(ret, p)
+	}
+	return ret
+}
+
+func filterPodsByEndpoint(ctx context.Context, d *dynamic.DynamicClient, pods []*corev1.Pod) ([]*corev1.Pod, error) {
+	namespaces := make(map[string]bool)
+	for _, p := range pods {
+		namespaces[p.Namespace] = true
+	}
+
+	endpoints := make(map[types.NamespacedName]bool)
+	podIdentities = make(map[types.NamespacedName]uint32)
+	for ns := range namespaces {
+		li, err := d.Resource(gvr.Endpoint).Namespace(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, ep := range li.Items {
+			nn := types.NamespacedName{Namespace: ep.GetNamespace(), Name: ep.GetName()}
+			endpoints[nn] = true
+
+			id, ok, err := unstructured.NestedInt64(ep.Object, "status", "identity", "id")
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			podIdentities[nn] = uint32(id)
+		}
+	}
+
+	ret := make([]*corev1.Pod, 0, len(pods))
+	for _, p := range pods {
+		nn := types.NamespacedName{Namespace: p.Namespace, Name: p.Name}
+		if !endpoints[nn] {
+			skipPod(p, SkipReasonNoEndpoint)
+			continue
+		}
+		if _, ok := podIdentities[nn]; !ok {
+			skipPod(p, SkipReasonNoIdentity)
+			continue
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+func filterPodsByProxy(ctx context.Context, c *kubernetes.Clientset, pods []*corev1.Pod) ([]*corev1.Pod, error) {
+	nodes, err := proxy.ListProxyNodes(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]*corev1.Pod, 0, len(pods))
+	for _, p := range pods {
+		if !nodes[p.Spec.NodeName] {
+			skipPod(p, SkipReasonNoProxy)
+			continue
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// samplePods picks one representative pod for each identity (and node, if requested).
+// The representative is the first pod in namespace/name order, so the choice is stable between runs.
+func samplePods(pods []*corev1.Pod) []*corev1.Pod {
+	pods = slices.Clone(pods)
+	slices.SortFunc(pods, func(x, y *corev1.Pod) int {
+		ret := strings.Compare(x.Namespace, y.Namespace)
+		if ret == 0 {
+			ret = strings.Compare(x.Name, y.Name)
+		}
+		return ret
+	})
+
+	type sampleKey struct {
+		identity uint32
+		node     string
+	}
+	representatives := make(map[sampleKey]types.NamespacedName)
+	podMembers = make(map[types.NamespacedName][]types.NamespacedName)
+
+	ret := make([]*corev1.Pod, 0)
+	for _, p := range pods {
+		nn := types.NamespacedName{Namespace: p.Namespace, Name: p.Name}
+		key := sampleKey{identity: podIdentities[nn]}
+		if selectorConfig.Sample == SampleNode {
+			key.node = p.Spec.NodeName
+		}
+		if rep, ok := representatives[key]; ok {
+			podMembers[rep] = append(podMembers[rep], nn)
+			continue
+		}
+		representatives[key] = nn
+		ret = append(ret, p)
+	}
+	return ret
 }
 
 func ListCiliumManagedPods(ctx context.Context, c *kubernetes.Clientset, nsOptions metav1.ListOptions, podOptions metav1.ListOptions) ([]*corev1.Pod, error) {
+	pods, err := listPods(ctx, c, nsOptions, podOptions)
+	if err != nil {
+		return nil, err
+	}
+	return filterPods(pods, false), nil
+}
+
+func listPods(ctx context.Context, c *kubernetes.Clientset, nsOptions metav1.ListOptions, podOptions metav1.ListOptions) ([]*corev1.Pod, error) {
 	nss, err := c.CoreV1().Namespaces().List(ctx, nsOptions)
 	if err != nil {
 		return nil, err
@@ -156,13 +404,6 @@ func ListCiliumManagedPods(ctx context.Context, c *kubernetes.Clientset, nsOptio
 		}
 
 		for _, p := range pods.Items {
-			// Skip non-relevant pods
-			if p.Spec.HostNetwork {
-				continue
-			}
-			if p.Status.Phase != corev1.PodRunning {
-				continue
-			}
 			ret = append(ret, &p)
 		}
 	}